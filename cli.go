@@ -0,0 +1,179 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"live-streamer/config"
+	"live-streamer/constant"
+	"live-streamer/library"
+	"log"
+	"os"
+	"strings"
+)
+
+// flags shared by all commands
+var (
+	configPath string
+	addr       string // overrides server.addr
+	socketPath string // overrides control_socket for ctl
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage: live-streamer [flags] [command] [args]
+
+Commands:
+  run               start streaming (default)
+  validate          check the config and the videos it lists, then exit
+  probe <file>...   print the media info of the files
+  ctl <command>     send a console command to the running instance,
+                    like next, prev, jump <index>, status or list,
+                    flags must be given before ctl
+  version           print the version
+
+Flags:
+`)
+	flag.PrintDefaults()
+}
+
+// parseCommandLine returns the command and its args, flags may be given
+// before or after the command. Everything after ctl belongs to the console
+// command, like its --json.
+func parseCommandLine() (string, []string) {
+	flag.StringVar(&configPath, "config", "", "config file, json, yaml or toml (default config.json, config.yaml, config.yml or config.toml)")
+	flag.StringVar(&addr, "addr", "", "address the web server listens on, overrides server.addr")
+	flag.StringVar(&socketPath, "socket", "", "control socket ctl connects to, overrides control_socket")
+	flag.Usage = usage
+	flag.Parse()
+	command := "run"
+	args := flag.Args()
+	if len(args) > 0 {
+		command = args[0]
+		args = args[1:]
+		if command != "ctl" {
+			_ = flag.CommandLine.Parse(args)
+			args = flag.Args()
+		}
+	}
+	if configPath == "" {
+		configPath = defaultConfigPath()
+	}
+	return command, args
+}
+
+// defaultConfigPath returns the first config file in the working directory
+// when --config is not given, config.json is preferred.
+func defaultConfigPath() string {
+	for _, path := range []string{"config.json", "config.yaml", "config.yml", "config.toml"} {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return "config.json"
+}
+
+// loadConfig reads the config file with the command line overrides applied,
+// it doesn't replace the running config.
+func loadConfig() (*config.Config, error) {
+	c, err := config.Load(configPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("config %s not exists", configPath)
+		}
+		return nil, err
+	}
+	if addr != "" {
+		c.Server.Addr = addr
+	}
+	return c, nil
+}
+
+func mustLoadConfig() {
+	c, err := loadConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	config.Set(c)
+}
+
+// validateCommand checks the config and every video it lists, videos that
+// can't be played make it fail.
+func validateCommand() int {
+	c, err := loadConfig()
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	fmt.Printf("config ok: %d videos, %d outputs, %d schedules\n", len(c.VideoList), len(c.Outputs), len(c.Schedule))
+
+	paths := make([]string, 0, len(c.VideoList))
+	seen := make(map[string]bool)
+	for _, item := range c.VideoList {
+		if !seen[item.Path] {
+			seen[item.Path] = true
+			paths = append(paths, item.Path)
+		}
+	}
+	for _, schedule := range c.Schedule {
+		videos, err := schedule.Videos()
+		if err != nil {
+			fmt.Printf("schedule %s: %v\n", schedule.Name, err)
+			return 1
+		}
+		for _, item := range videos {
+			if !seen[item.Path] {
+				seen[item.Path] = true
+				paths = append(paths, item.Path)
+			}
+		}
+	}
+
+	library.NewLibrary(c.MediaCache)
+	bad := 0
+	for _, path := range paths {
+		info, err := library.GlobalLibrary.Get(path)
+		if err != nil {
+			info.Error = err.Error()
+		}
+		if info.Error != "" {
+			bad++
+			fmt.Printf("bad\t%s\t%s\n", path, info.Error)
+		}
+	}
+	if bad > 0 {
+		fmt.Printf("%d of %d videos can't be played\n", bad, len(paths))
+		return 1
+	}
+	fmt.Printf("all %d videos are playable\n", len(paths))
+	return 0
+}
+
+// probeCommand prints the media info of the files, without using the cache
+func probeCommand(paths []string) int {
+	if len(paths) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: live-streamer probe <file>...")
+		return 2
+	}
+	code := 0
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			fmt.Printf("%s\t%v\n", path, err)
+			code = 1
+			continue
+		}
+		info := library.Probe(path)
+		if info.Error != "" {
+			code = 1
+		}
+		fmt.Printf("%s\t%s\n", path, formatMedia(info))
+	}
+	return code
+}
+
+func runCommand(args []string) {
+	if len(args) > 0 {
+		log.Fatalf("unexpected args: %s", strings.Join(args, " "))
+	}
+	mustLoadConfig()
+	fmt.Println("Version: " + constant.Version)
+	run()
+}
@@ -4,16 +4,36 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"live-streamer/constant"
 	"live-streamer/utils"
-	"log"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync/atomic"
+	"time"
 )
 
 type OutputConfig struct {
-	RTMPServer string `json:"rtmp_server"`
-	StreamKey  string `json:"stream_key"`
+	Name                string `json:"name"`
+	RTMPServer          string `json:"rtmp_server"`
+	StreamKey           string `json:"stream_key"`
+	StreamKeyFile       string `json:"stream_key_file"`    // file holding stream_key, like a docker secret
+	BackupRTMPServer    string `json:"backup_rtmp_server"` // used while rtmp_server keeps failing
+	BackupStreamKey     string `json:"backup_stream_key"`  // defaults to stream_key
+	BackupStreamKeyFile string `json:"backup_stream_key_file"`
+	Disabled            bool   `json:"disabled"`
+}
+
+func (o OutputConfig) URL() string {
+	return fmt.Sprintf("%s/%s", o.RTMPServer, o.StreamKey)
+}
+
+// Backup returns the output pushing to the backup endpoint
+func (o OutputConfig) Backup() OutputConfig {
+	o.RTMPServer = o.BackupRTMPServer
+	o.StreamKey = o.BackupStreamKey
+	return o
 }
 
 type InputItem struct {
@@ -36,91 +56,191 @@ type PlayConfig struct {
 	AudioSampleRate int    `json:"audio_sample_rate"`
 	OutputFormat    string `json:"output_format"`
 	CustomArgs      string `json:"custom_args"`
+	Gapless         bool   `json:"gapless"` // keep a single rtmp session across videos
+	Slate           string `json:"slate"`   // image or video looped while paused
+	Mode            string `json:"mode"`    // order the videos are played in
 }
 
 type LogConfig struct {
 	PlayState bool `json:"play_state"`
+	MaxLines  int  `json:"max_lines"` // lines of output kept for web clients
+}
+
+type RetryConfig struct {
+	InitialBackoff    int `json:"initial_backoff"`    // seconds
+	MaxBackoff        int `json:"max_backoff"`        // seconds
+	MaxInputFailures  int `json:"max_input_failures"` // a video failing this many times in a row is skipped
+	FailoverThreshold int `json:"failover_threshold"` // publish failures before switching to the backup endpoint
+}
+
+// ScheduleConfig is a program aired at a time of day, its videos are played
+// in order before the playlist continues.
+type ScheduleConfig struct {
+	Name      string         `json:"name"`
+	Input     []string       `json:"input"`      // videos or dirs, dirs are read when the program starts
+	At        string         `json:"at"`         // time of day like "08:00" or "20:30:00"
+	Days      []string       `json:"days"`       // weekdays like "mon", every day when empty
+	HardStart bool           `json:"hard_start"` // cut the playing video instead of waiting for it to end
+	Hour      int            `json:"-"`          // parsed from At
+	Minute    int            `json:"-"`
+	Second    int            `json:"-"`
+	Weekdays  []time.Weekday `json:"-"`
+}
+
+// Videos returns the videos of the program
+func (s ScheduleConfig) Videos() ([]InputItem, error) {
+	var videos []InputItem
+	for _, path := range s.Input {
+		stat, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !stat.IsDir() {
+			videos = append(videos, InputItem{Path: path})
+			continue
+		}
+		dirVideos, err := getAllVideos(path)
+		if err != nil {
+			return nil, err
+		}
+		videos = append(videos, dirVideos...)
+	}
+	return videos, nil
+}
+
+// OnDay returns whether the program airs on day
+func (s ScheduleConfig) OnDay(day time.Weekday) bool {
+	return len(s.Weekdays) == 0 || slices.Contains(s.Weekdays, day)
+}
+
+type EPGConfig struct {
+	ChannelID   string `json:"channel_id"`
+	ChannelName string `json:"channel_name"`
+	Hours       int    `json:"hours"` // how far ahead the guide is projected
 }
 
 type ServerConfig struct {
-	Addr  string `json:"addr"`
-	Token string `json:"token"`
+	Addr      string `json:"addr"`
+	Token     string `json:"token"`
+	TokenFile string `json:"token_file"` // file holding token, like a docker secret
 }
 
 type Config struct {
-	Input      []any        `json:"input"`
-	InputItems []InputItem  `json:"-"` // contains video file or dir
-	VideoList  []InputItem  `json:"-"` // only contains video file
-	Play       PlayConfig   `json:"play"`
-	Output     OutputConfig `json:"output"`
-	Log        LogConfig    `json:"log"`
-	Server     ServerConfig `json:"server"`
+	Input      []any            `json:"input"`
+	InputItems []InputItem      `json:"-"` // contains video file or dir
+	VideoList  []InputItem      `json:"-"` // only contains video file
+	Play       PlayConfig       `json:"play"`
+	Output     OutputConfig     `json:"output"`
+	Outputs    []OutputConfig   `json:"outputs"` // simulcast destinations, output is merged as the first one
+	Log        LogConfig        `json:"log"`
+	Retry      RetryConfig      `json:"retry"`
+	Server     ServerConfig     `json:"server"`
+	Schedule   []ScheduleConfig `json:"schedule"` // programs overriding the playlist at set times
+	EPG        EPGConfig        `json:"epg"`
+	StateFile  string           `json:"state_file"`  // remembers the playing video across restarts
+	MediaCache string           `json:"media_cache"` // probed media info of the videos
+	// unix socket the ctl command controls the running instance through
+	ControlSocket string `json:"control_socket"`
 }
 
-var GlobalConfig Config
+var current atomic.Pointer[Config]
 
-func init() {
-	GlobalConfig = Config{}
-	err := readConfig("config.json")
-	if len(GlobalConfig.Input) == 0 {
-		log.Fatal("No input video found")
-	}
-	if err != nil {
-		if os.IsNotExist(err) {
-			log.Fatal("Config not exists")
-		} else {
-			log.Fatal(err)
-		}
+const DefaultControlSocket = "live-streamer.sock"
+
+// Get returns the running config, it must not be modified
+func Get() *Config {
+	return current.Load()
+}
+
+// Set replaces the running config, the secrets of the replaced config are
+// still redacted until ForgetReplacedSecrets is called.
+func Set(c *Config) {
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	if old := current.Swap(c); old != nil {
+		replacedSecrets = append(replacedSecrets, secrets(old)...)
 	}
+	redactor.Store(newRedactor(append(secrets(c), replacedSecrets...)))
 }
 
-func readConfig(configPath string) error {
+// Load reads and validates a config file without touching the running config
+func Load(configPath string) (*Config, error) {
 	stat, err := os.Stat(configPath)
 	if err != nil {
-		return fmt.Errorf("config read failed: %v", err)
+		if os.IsNotExist(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("config read failed: %v", err)
 	}
 	if stat.IsDir() {
-		return os.ErrNotExist
+		return nil, os.ErrNotExist
 	}
 	databytes, err := os.ReadFile(configPath)
 	if err != nil {
-		return fmt.Errorf("Config read failed: %v", err)
+		return nil, fmt.Errorf("Config read failed: %v", err)
 	}
-	if err = json.Unmarshal(databytes, &GlobalConfig); err != nil {
-		return fmt.Errorf("config unmarshal failed: %v", err)
+	c := &Config{}
+	if err = decodeConfig(configPath, databytes, c); err != nil {
+		return nil, err
 	}
-	err = validateConfig()
-	if err != nil {
-		return fmt.Errorf("config validate failed: %v", err)
+	if err = applyEnv(c); err != nil {
+		return nil, fmt.Errorf("config env override failed: %v", err)
 	}
-	return nil
+	if err = validateConfig(c); err != nil {
+		return nil, fmt.Errorf("config validate failed: %v", err)
+	}
+	if len(c.Input) == 0 {
+		return nil, errors.New("No input video found")
+	}
+	return c, nil
 }
 
-func validateConfig() error {
-	if err := validateInputConfig(); err != nil {
+func validateConfig(c *Config) error {
+	if err := validateInputConfig(c); err != nil {
+		return err
+	}
+	if err := validateOutputConfig(c); err != nil {
+		return err
+	}
+	if err := validatePlayConfig(c); err != nil {
 		return err
 	}
-	if err := validateOutputConfig(); err != nil {
+	if err := validateLogConfig(c); err != nil {
 		return err
 	}
-	if err := validatePlayConfig(); err != nil {
+	if err := validateRetryConfig(c); err != nil {
 		return err
 	}
-	if err := validateServerConfig(); err != nil {
+	if err := validateServerConfig(c); err != nil {
 		return err
 	}
+	if err := validateScheduleConfig(c); err != nil {
+		return err
+	}
+	if err := validateEPGConfig(c); err != nil {
+		return err
+	}
+	if c.StateFile == "" {
+		c.StateFile = "state.json"
+	}
+	if c.MediaCache == "" {
+		c.MediaCache = "media_cache.json"
+	}
+	if c.ControlSocket == "" {
+		c.ControlSocket = DefaultControlSocket
+	}
 	return nil
 }
 
-func validateInputConfig() error {
-	if GlobalConfig.Input == nil {
+func validateInputConfig(c *Config) error {
+	if c.Input == nil {
 		return errors.New("video_path is nil")
 	}
 
-	GlobalConfig.InputItems = make([]InputItem, 0, len(GlobalConfig.Input))
-	GlobalConfig.VideoList = []InputItem{}
+	c.InputItems = make([]InputItem, 0, len(c.Input))
+	c.VideoList = []InputItem{}
 
-	for i, item := range GlobalConfig.Input {
+	for i, item := range c.Input {
 		var inputItem InputItem
 
 		switch v := item.(type) {
@@ -163,82 +283,262 @@ func validateInputConfig() error {
 			if err != nil {
 				return fmt.Errorf("video_path[%d] get videos error: %v", i, err)
 			}
-			GlobalConfig.VideoList = append(GlobalConfig.VideoList, videos...)
+			c.VideoList = append(c.VideoList, videos...)
 		} else {
 			inputItem.ItemType = "file"
 			if !utils.IsSupportedVideo(inputItem.Path) {
 				return fmt.Errorf("video_path[%d] is not supported", i)
 			}
-			GlobalConfig.VideoList = append(GlobalConfig.VideoList, inputItem)
+			c.VideoList = append(c.VideoList, inputItem)
 		}
 
-		GlobalConfig.InputItems = append(GlobalConfig.InputItems, inputItem)
+		c.InputItems = append(c.InputItems, inputItem)
 	}
 
 	return nil
 }
 
-func validateOutputConfig() error {
-	if GlobalConfig.Output.RTMPServer == "" {
+func validateOutputConfig(c *Config) error {
+	outputs := make([]OutputConfig, 0, len(c.Outputs)+1)
+	if c.Output.RTMPServer != "" || c.Output.StreamKey != "" || c.Output.StreamKeyFile != "" {
+		if c.Output.Name == "" {
+			c.Output.Name = "default"
+		}
+		outputs = append(outputs, c.Output)
+	}
+	outputs = append(outputs, c.Outputs...)
+	if len(outputs) == 0 {
 		return errors.New("rtmp_server is empty")
-	} else if !strings.HasPrefix(GlobalConfig.Output.RTMPServer, "rtmp://") &&
-		!strings.HasPrefix(GlobalConfig.Output.RTMPServer, "rtmps://") {
+	}
+
+	names := make(map[string]bool)
+	enabled := 0
+	for i := range outputs {
+		if err := validateOutput(&outputs[i]); err != nil {
+			return fmt.Errorf("outputs[%d]: %v", i, err)
+		}
+		if outputs[i].Name == "" {
+			outputs[i].Name = fmt.Sprintf("output%d", i)
+		}
+		if names[outputs[i].Name] {
+			return fmt.Errorf("outputs[%d]: duplicate name %s", i, outputs[i].Name)
+		}
+		names[outputs[i].Name] = true
+		if !outputs[i].Disabled {
+			enabled++
+		}
+	}
+	if enabled == 0 {
+		return errors.New("all outputs are disabled")
+	}
+	c.Outputs = outputs
+	return nil
+}
+
+func validateOutput(output *OutputConfig) error {
+	if err := resolveSecret("stream_key", &output.StreamKey, output.StreamKeyFile); err != nil {
+		return err
+	}
+	if err := resolveSecret("backup_stream_key", &output.BackupStreamKey, output.BackupStreamKeyFile); err != nil {
+		return err
+	}
+	if output.RTMPServer == "" {
+		return errors.New("rtmp_server is empty")
+	} else if !strings.HasPrefix(output.RTMPServer, "rtmp://") &&
+		!strings.HasPrefix(output.RTMPServer, "rtmps://") {
 		return errors.New("rtmp_server is not a valid rtmp server")
 	} else {
-		GlobalConfig.Output.RTMPServer = strings.TrimSuffix(GlobalConfig.Output.RTMPServer, "/")
+		output.RTMPServer = strings.TrimSuffix(output.RTMPServer, "/")
 	}
-	if GlobalConfig.Output.StreamKey == "" {
+	if output.StreamKey == "" {
 		return errors.New("stream_key is empty")
 	} else {
-		GlobalConfig.Output.StreamKey = strings.TrimPrefix(GlobalConfig.Output.StreamKey, "/")
+		output.StreamKey = strings.TrimPrefix(output.StreamKey, "/")
+	}
+	if output.BackupRTMPServer != "" {
+		if !strings.HasPrefix(output.BackupRTMPServer, "rtmp://") &&
+			!strings.HasPrefix(output.BackupRTMPServer, "rtmps://") {
+			return errors.New("backup_rtmp_server is not a valid rtmp server")
+		}
+		output.BackupRTMPServer = strings.TrimSuffix(output.BackupRTMPServer, "/")
+		if output.BackupStreamKey == "" {
+			output.BackupStreamKey = output.StreamKey
+		} else {
+			output.BackupStreamKey = strings.TrimPrefix(output.BackupStreamKey, "/")
+		}
 	}
 	return nil
 }
 
-func validatePlayConfig() error {
-	if GlobalConfig.Play.VideoCodec == "" {
-		GlobalConfig.Play.VideoCodec = "libx264"
+func validatePlayConfig(c *Config) error {
+	if c.Play.VideoCodec == "" {
+		c.Play.VideoCodec = "libx264"
+	}
+	if c.Play.Preset == "" {
+		c.Play.Preset = "fast"
+	}
+	if c.Play.CRF == 0 {
+		c.Play.CRF = 23
+	}
+	if c.Play.MaxRate == "" {
+		c.Play.MaxRate = "8000k"
+	}
+	if c.Play.BufSize == "" {
+		c.Play.BufSize = "12000k"
+	}
+	if c.Play.Scale == "" {
+		c.Play.Scale = "1920:1080:force_original_aspect_ratio=decrease,pad=1920:1080:(ow-iw)/2:(oh-ih)/2"
+	}
+	if c.Play.FrameRate == 0 {
+		c.Play.FrameRate = 30
+	}
+	if c.Play.AudioCodec == "" {
+		c.Play.AudioCodec = "aac"
+	}
+	if c.Play.AudioBitrate == "" {
+		c.Play.AudioBitrate = "192k"
 	}
-	if GlobalConfig.Play.Preset == "" {
-		GlobalConfig.Play.Preset = "fast"
+	if c.Play.AudioSampleRate == 0 {
+		c.Play.AudioSampleRate = 48000
 	}
-	if GlobalConfig.Play.CRF == 0 {
-		GlobalConfig.Play.CRF = 23
+	if c.Play.OutputFormat == "" {
+		c.Play.OutputFormat = "flv"
 	}
-	if GlobalConfig.Play.MaxRate == "" {
-		GlobalConfig.Play.MaxRate = "8000k"
+	if c.Play.Slate != "" {
+		stat, err := os.Stat(c.Play.Slate)
+		if err != nil {
+			return fmt.Errorf("slate stat failed: %v", err)
+		}
+		if stat.IsDir() ||
+			(!utils.IsSupportedVideo(c.Play.Slate) && !utils.IsSupportedImage(c.Play.Slate)) {
+			return errors.New("slate is not a supported image or video")
+		}
 	}
-	if GlobalConfig.Play.BufSize == "" {
-		GlobalConfig.Play.BufSize = "12000k"
+	if c.Play.Mode == "" {
+		c.Play.Mode = constant.PlayModeSequential
 	}
-	if GlobalConfig.Play.Scale == "" {
-		GlobalConfig.Play.Scale = "1920:1080:force_original_aspect_ratio=decrease,pad=1920:1080:(ow-iw)/2:(oh-ih)/2"
+	if !slices.Contains(constant.SupportedPlayModes, c.Play.Mode) {
+		return fmt.Errorf("mode must be one of %s", strings.Join(constant.SupportedPlayModes, ", "))
 	}
-	if GlobalConfig.Play.FrameRate == 0 {
-		GlobalConfig.Play.FrameRate = 30
+	return nil
+}
+
+func validateLogConfig(c *Config) error {
+	if c.Log.MaxLines < 0 {
+		return errors.New("max_lines is negative")
 	}
-	if GlobalConfig.Play.AudioCodec == "" {
-		GlobalConfig.Play.AudioCodec = "aac"
+	if c.Log.MaxLines == 0 {
+		c.Log.MaxLines = 1000
 	}
-	if GlobalConfig.Play.AudioBitrate == "" {
-		GlobalConfig.Play.AudioBitrate = "192k"
+	return nil
+}
+
+func validateRetryConfig(c *Config) error {
+	if c.Retry.InitialBackoff <= 0 {
+		c.Retry.InitialBackoff = 1
+	}
+	if c.Retry.MaxBackoff <= 0 {
+		c.Retry.MaxBackoff = 60
+	}
+	if c.Retry.MaxBackoff < c.Retry.InitialBackoff {
+		return errors.New("max_backoff is less than initial_backoff")
+	}
+	if c.Retry.MaxInputFailures <= 0 {
+		c.Retry.MaxInputFailures = 3
+	}
+	if c.Retry.FailoverThreshold <= 0 {
+		c.Retry.FailoverThreshold = 3
+	}
+	return nil
+}
+
+func validateServerConfig(c *Config) error {
+	if c.Server.Addr == "" {
+		c.Server.Addr = ":8080"
 	}
-	if GlobalConfig.Play.AudioSampleRate == 0 {
-		GlobalConfig.Play.AudioSampleRate = 48000
+	if err := resolveSecret("token", &c.Server.Token, c.Server.TokenFile); err != nil {
+		return err
 	}
-	if GlobalConfig.Play.OutputFormat == "" {
-		GlobalConfig.Play.OutputFormat = "flv"
+	return nil
+}
+
+func validateScheduleConfig(c *Config) error {
+	names := make(map[string]bool)
+	for i := range c.Schedule {
+		schedule := &c.Schedule[i]
+		if schedule.Name == "" {
+			schedule.Name = fmt.Sprintf("schedule[%d]", i)
+		}
+		if names[schedule.Name] {
+			return fmt.Errorf("schedule %s is duplicated", schedule.Name)
+		}
+		names[schedule.Name] = true
+
+		if len(schedule.Input) == 0 {
+			return fmt.Errorf("schedule %s input is empty", schedule.Name)
+		}
+		for _, path := range schedule.Input {
+			stat, err := os.Stat(path)
+			if err != nil {
+				return fmt.Errorf("schedule %s input stat failed: %v", schedule.Name, err)
+			}
+			if !stat.IsDir() && !utils.IsSupportedVideo(path) {
+				return fmt.Errorf("schedule %s input %s is not supported", schedule.Name, path)
+			}
+		}
+
+		at, err := time.Parse("15:04:05", schedule.At)
+		if err != nil {
+			at, err = time.Parse("15:04", schedule.At)
+		}
+		if err != nil {
+			return fmt.Errorf("schedule %s at must be like 08:00 or 08:00:00", schedule.Name)
+		}
+		schedule.Hour, schedule.Minute, schedule.Second = at.Clock()
+
+		schedule.Weekdays = nil
+		for _, day := range schedule.Days {
+			weekday, ok := parseWeekday(day)
+			if !ok {
+				return fmt.Errorf("schedule %s day %s is invalid", schedule.Name, day)
+			}
+			schedule.Weekdays = append(schedule.Weekdays, weekday)
+		}
 	}
 	return nil
 }
 
-func validateServerConfig() error {
-	if GlobalConfig.Server.Addr == "" {
-		GlobalConfig.Server.Addr = ":8080"
+func validateEPGConfig(c *Config) error {
+	if c.EPG.ChannelID == "" {
+		c.EPG.ChannelID = "live-streamer"
+	}
+	if c.EPG.ChannelName == "" {
+		c.EPG.ChannelName = "Live Streamer"
+	}
+	if c.EPG.Hours < 0 {
+		return errors.New("epg hours is negative")
+	}
+	if c.EPG.Hours == 0 {
+		c.EPG.Hours = 24
 	}
 	return nil
 }
 
+// parseWeekday parses weekdays like "mon" or "Monday"
+func parseWeekday(day string) (time.Weekday, bool) {
+	day = strings.ToLower(strings.TrimSpace(day))
+	if len(day) < 3 {
+		return 0, false
+	}
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		name := strings.ToLower(weekday.String())
+		if strings.HasPrefix(name, day) {
+			return weekday, true
+		}
+	}
+	return 0, false
+}
+
 func getAllVideos(dirPath string) ([]InputItem, error) {
 	res := []InputItem{}
 	err := filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
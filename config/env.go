@@ -0,0 +1,90 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// prefix of the environment variables overriding the config
+const envPrefix = "LIVE_STREAMER"
+
+// applyEnv overrides the fields of c with environment variables named after
+// the json path of the field, like LIVE_STREAMER_OUTPUT_STREAM_KEY for
+// output.stream_key. Elements of a list are addressed by index, like
+// LIVE_STREAMER_OUTPUTS_0_STREAM_KEY, and a list of strings is given
+// separated by commas, like LIVE_STREAMER_INPUT=./videos,./ads.
+func applyEnv(c *Config) error {
+	return applyEnvValue(reflect.ValueOf(c).Elem(), envPrefix)
+}
+
+func applyEnvValue(v reflect.Value, name string) error {
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			tag, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if !field.IsExported() || tag == "" || tag == "-" {
+				continue
+			}
+			if err := applyEnvValue(v.Field(i), name+"_"+strings.ToUpper(tag)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Slice:
+		switch v.Type().Elem().Kind() {
+		case reflect.Struct:
+			for i := 0; i < v.Len(); i++ {
+				if err := applyEnvValue(v.Index(i), fmt.Sprintf("%s_%d", name, i)); err != nil {
+					return err
+				}
+			}
+		case reflect.String, reflect.Interface:
+			value, ok := os.LookupEnv(name)
+			if !ok {
+				return nil
+			}
+			items := strings.Split(value, ",")
+			list := reflect.MakeSlice(v.Type(), 0, len(items))
+			for _, item := range items {
+				if item = strings.TrimSpace(item); item != "" {
+					list = reflect.Append(list, reflect.ValueOf(item))
+				}
+			}
+			v.Set(list)
+		}
+		return nil
+	}
+
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s is not a bool: %v", name, err)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s is not an integer: %v", name, err)
+		}
+		v.SetInt(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%s is not a number: %v", name, err)
+		}
+		v.SetFloat(f)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want func(c *Config)
+	}{
+		{
+			name: "nested string",
+			env:  map[string]string{"LIVE_STREAMER_OUTPUT_STREAM_KEY": "secret"},
+			want: func(c *Config) { c.Output.StreamKey = "secret" },
+		},
+		{
+			name: "bool and int",
+			env:  map[string]string{"LIVE_STREAMER_PLAY_GAPLESS": "true", "LIVE_STREAMER_PLAY_CRF": "18"},
+			want: func(c *Config) { c.Play.Gapless = true; c.Play.CRF = 18 },
+		},
+		{
+			name: "list element by index",
+			env:  map[string]string{"LIVE_STREAMER_OUTPUTS_1_DISABLED": "1", "LIVE_STREAMER_OUTPUTS_2_NAME": "ignored"},
+			want: func(c *Config) { c.Outputs[1].Disabled = true },
+		},
+		{
+			name: "list of strings",
+			env:  map[string]string{"LIVE_STREAMER_INPUT": "./videos, ./ads,,"},
+			want: func(c *Config) { c.Input = []any{"./videos", "./ads"} },
+		},
+		{
+			name: "list of strings in a list",
+			env:  map[string]string{"LIVE_STREAMER_SCHEDULE_0_DAYS": "sat,sun"},
+			want: func(c *Config) { c.Schedule[0].Days = []string{"sat", "sun"} },
+		},
+		{
+			name: "empty value",
+			env:  map[string]string{"LIVE_STREAMER_SERVER_TOKEN": ""},
+			want: func(c *Config) { c.Server.Token = "" },
+		},
+		{
+			name: "fields without a json name",
+			env:  map[string]string{"LIVE_STREAMER_VIDEOLIST": "./x.mp4", "LIVE_STREAMER_SCHEDULE_0_HOUR": "3"},
+			want: func(c *Config) {},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			c := newEnvTestConfig()
+			if err := applyEnv(c); err != nil {
+				t.Fatal(err)
+			}
+			want := newEnvTestConfig()
+			tt.want(want)
+			if !reflect.DeepEqual(c, want) {
+				t.Errorf("got %+v, want %+v", c, want)
+			}
+		})
+	}
+}
+
+func TestApplyEnvInvalid(t *testing.T) {
+	tests := map[string]string{
+		"LIVE_STREAMER_PLAY_GAPLESS":          "maybe",
+		"LIVE_STREAMER_PLAY_CRF":              "high",
+		"LIVE_STREAMER_RETRY_MAX_BACKOFF":     "1.5",
+		"LIVE_STREAMER_OUTPUTS_0_DISABLED":    "no way",
+		"LIVE_STREAMER_LOG_MAX_LINES":         "1e3",
+		"LIVE_STREAMER_SCHEDULE_0_HARD_START": "y",
+	}
+	for key, value := range tests {
+		t.Run(key, func(t *testing.T) {
+			t.Setenv(key, value)
+			if err := applyEnv(newEnvTestConfig()); err == nil {
+				t.Errorf("%s=%q accepted, want error", key, value)
+			}
+		})
+	}
+}
+
+func newEnvTestConfig() *Config {
+	return &Config{
+		Input:  []any{"./videos"},
+		Output: OutputConfig{RTMPServer: "rtmp://a.example.com/live", StreamKey: "key"},
+		Outputs: []OutputConfig{
+			{Name: "main"},
+			{Name: "second"},
+		},
+		Server:   ServerConfig{Token: "token"},
+		Schedule: []ScheduleConfig{{Name: "news", At: "20:00", Hour: 20}},
+	}
+}
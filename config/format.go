@@ -0,0 +1,40 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// decodeConfig decodes the config file by its extension, yaml and toml are
+// converted to json so every format uses the same field names.
+func decodeConfig(configPath string, data []byte, c *Config) error {
+	var raw map[string]any
+	switch ext := strings.ToLower(filepath.Ext(configPath)); ext {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return fmt.Errorf("config unmarshal failed: %v", err)
+		}
+	case ".toml":
+		if err := toml.Unmarshal(data, &raw); err != nil {
+			return fmt.Errorf("config unmarshal failed: %v", err)
+		}
+	default:
+		if err := json.Unmarshal(data, c); err != nil {
+			return fmt.Errorf("config unmarshal failed: %v", err)
+		}
+		return nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return fmt.Errorf("config convert failed: %v", err)
+	}
+	if err := json.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("config unmarshal failed: %v", err)
+	}
+	return nil
+}
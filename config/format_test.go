@@ -0,0 +1,115 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeConfig(t *testing.T) {
+	want := &Config{
+		Input: []any{"./videos", map[string]any{"path": "./ads/a.mp4", "start": "10"}},
+		Play:  PlayConfig{CRF: 23, Gapless: true, Mode: "shuffle"},
+		Outputs: []OutputConfig{
+			{Name: "main", RTMPServer: "rtmp://a.example.com/live", StreamKey: "key"},
+			{Name: "second", RTMPServer: "rtmp://b.example.com/live", Disabled: true},
+		},
+		Schedule: []ScheduleConfig{{Name: "news", Input: []string{"./news"}, At: "20:00", Days: []string{"mon", "fri"}}},
+	}
+	tests := []struct {
+		path string
+		data string
+	}{
+		{"config.json", `{
+  "input": ["./videos", {"path": "./ads/a.mp4", "start": "10"}],
+  "play": {"crf": 23, "gapless": true, "mode": "shuffle"},
+  "outputs": [
+    {"name": "main", "rtmp_server": "rtmp://a.example.com/live", "stream_key": "key"},
+    {"name": "second", "rtmp_server": "rtmp://b.example.com/live", "disabled": true}
+  ],
+  "schedule": [{"name": "news", "input": ["./news"], "at": "20:00", "days": ["mon", "fri"]}]
+}`},
+		{"config.yaml", `
+input:
+  - ./videos
+  - path: ./ads/a.mp4
+    start: "10"
+play:
+  crf: 23
+  gapless: true
+  mode: shuffle
+outputs:
+  - name: main
+    rtmp_server: rtmp://a.example.com/live
+    stream_key: key
+  - name: second
+    rtmp_server: rtmp://b.example.com/live
+    disabled: true
+schedule:
+  - name: news
+    input: [./news]
+    at: "20:00"
+    days: [mon, fri]
+`},
+		{"CONFIG.YML", `
+input: [./videos, {path: ./ads/a.mp4, start: "10"}]
+play: {crf: 23, gapless: true, mode: shuffle}
+outputs:
+  - {name: main, rtmp_server: "rtmp://a.example.com/live", stream_key: key}
+  - {name: second, rtmp_server: "rtmp://b.example.com/live", disabled: true}
+schedule:
+  - {name: news, input: [./news], at: "20:00", days: [mon, fri]}
+`},
+		{"config.toml", `
+input = ["./videos", {path = "./ads/a.mp4", start = "10"}]
+
+[play]
+crf = 23
+gapless = true
+mode = "shuffle"
+
+[[outputs]]
+name = "main"
+rtmp_server = "rtmp://a.example.com/live"
+stream_key = "key"
+
+[[outputs]]
+name = "second"
+rtmp_server = "rtmp://b.example.com/live"
+disabled = true
+
+[[schedule]]
+name = "news"
+input = ["./news"]
+at = "20:00"
+days = ["mon", "fri"]
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			c := &Config{}
+			if err := decodeConfig(tt.path, []byte(tt.data), c); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(c, want) {
+				t.Errorf("decoded %+v, want %+v", c, want)
+			}
+		})
+	}
+}
+
+func TestDecodeConfigInvalid(t *testing.T) {
+	tests := []struct {
+		path string
+		data string
+	}{
+		{"config.json", `{"input": `},
+		{"config.yaml", "input: [./videos"},
+		{"config.toml", `input = "./videos`},
+		{"config.toml", `play = "fast"`},
+	}
+	for _, tt := range tests {
+		if err := decodeConfig(tt.path, []byte(tt.data), &Config{}); err == nil {
+			t.Errorf("decodeConfig(%s, %q) succeeded, want error", tt.path, tt.data)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// shown in place of stream keys and tokens
+const redacted = "******"
+
+// readSecret reads a secret like a docker or kubernetes secret file, the
+// trailing newline is dropped.
+func readSecret(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	secret := strings.TrimSpace(string(data))
+	if secret == "" {
+		return "", errors.New("file is empty")
+	}
+	return secret, nil
+}
+
+// resolveSecret sets value from file unless the value is given directly
+func resolveSecret(name string, value *string, file string) error {
+	if file == "" {
+		return nil
+	}
+	if *value != "" {
+		return fmt.Errorf("%s and %s_file are both set", name, name)
+	}
+	secret, err := readSecret(file)
+	if err != nil {
+		return fmt.Errorf("%s_file read failed: %v", name, err)
+	}
+	*value = secret
+	return nil
+}
+
+var (
+	secretsMu sync.Mutex
+	// secrets of replaced configs, ffmpeg started with them may still be
+	// running and logging them
+	replacedSecrets []string
+	redactor        atomic.Pointer[strings.Replacer]
+)
+
+// secrets returns the stream keys and the token of c
+func secrets(c *Config) []string {
+	if c == nil {
+		return nil
+	}
+	var values []string
+	for _, output := range c.Outputs {
+		values = append(values, output.StreamKey, output.BackupStreamKey)
+	}
+	return append(values, c.Server.Token)
+}
+
+// newRedactor returns a replacer hiding secrets
+func newRedactor(secrets []string) *strings.Replacer {
+	secrets = slices.DeleteFunc(slices.Clone(secrets), func(s string) bool { return s == "" })
+	// a secret containing another one must be replaced first
+	slices.SortFunc(secrets, func(a, b string) int {
+		return cmp.Or(cmp.Compare(len(b), len(a)), strings.Compare(a, b))
+	})
+	secrets = slices.Compact(secrets)
+	pairs := make([]string, 0, len(secrets)*2)
+	for _, secret := range secrets {
+		pairs = append(pairs, secret, redacted)
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// ForgetReplacedSecrets stops redacting the secrets of replaced configs, called
+// when the process pushing to the rtmp servers is started again with the
+// running config.
+func ForgetReplacedSecrets() {
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	if len(replacedSecrets) == 0 {
+		return
+	}
+	replacedSecrets = nil
+	redactor.Store(newRedactor(secrets(Get())))
+}
+
+// Redact hides the stream keys and the token of the running config in s
+func Redact(s string) string {
+	r := redactor.Load()
+	if r == nil {
+		return s
+	}
+	return r.Replace(s)
+}
+
+// RedactArgs is Redact for command args
+func RedactArgs(args []string) []string {
+	redactedArgs := make([]string, len(args))
+	for i, arg := range args {
+		redactedArgs[i] = Redact(arg)
+	}
+	return redactedArgs
+}
@@ -0,0 +1,128 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveSecret(t *testing.T) {
+	dir := t.TempDir()
+	file := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	key := file("key", "file-key\n")
+	empty := file("empty", " \n")
+
+	tests := []struct {
+		name    string
+		value   string
+		file    string
+		want    string
+		wantErr string
+	}{
+		{"inline only", "inline-key", "", "inline-key", ""},
+		{"neither", "", "", "", ""},
+		{"file only, newline trimmed", "", key, "file-key", ""},
+		{"both set", "inline-key", key, "inline-key", "stream_key and stream_key_file are both set"},
+		{"empty file", "", empty, "", "file is empty"},
+		{"missing file", "", filepath.Join(dir, "missing"), "", "stream_key_file read failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value := tt.value
+			err := resolveSecret("stream_key", &value, tt.file)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("error = %v, want %q", err, tt.wantErr)
+			}
+			if value != tt.want {
+				t.Errorf("value = %q, want %q", value, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewRedactor(t *testing.T) {
+	tests := []struct {
+		name    string
+		secrets []string
+		in      string
+		want    string
+	}{
+		{"url", []string{"key1"}, "rtmp://a/live/key1", "rtmp://a/live/******"},
+		{"secret containing another", []string{"key", "key-backup"}, "rtmp://a/live/key-backup", "rtmp://a/live/******"},
+		{"contained one given first", []string{"abc", "xabcx"}, "xabcx abc", "****** ******"},
+		{"duplicates and empty", []string{"", "tok", "key", "tok", ""}, "tok key", "****** ******"},
+		{"nothing to hide", nil, "rtmp://a/live/key", "rtmp://a/live/key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newRedactor(tt.secrets).Replace(tt.in); got != tt.want {
+				t.Errorf("redacted %q to %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactArgs(t *testing.T) {
+	setTestConfig(t, &Config{
+		Outputs: []OutputConfig{
+			{Name: "a", RTMPServer: "rtmp://a/live", StreamKey: "key-a", BackupStreamKey: "key-a-backup"},
+			{Name: "b", RTMPServer: "rtmp://b/live", StreamKey: "key-b"},
+		},
+		Server: ServerConfig{Token: "token"},
+	})
+	args := []string{
+		"-f", "tee",
+		"[f=flv:onfail=ignore]rtmp://a/live/key-a|[f=flv:onfail=ignore]rtmp://b/live/key-b|[f=flv:onfail=ignore]rtmp://c/live/key-a-backup",
+		"token",
+	}
+	want := []string{
+		"-f", "tee",
+		"[f=flv:onfail=ignore]rtmp://a/live/******|[f=flv:onfail=ignore]rtmp://b/live/******|[f=flv:onfail=ignore]rtmp://c/live/******",
+		"******",
+	}
+	got := RedactArgs(args)
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("arg %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+	if args[2] == got[2] {
+		t.Error("args modified in place")
+	}
+}
+
+func TestRedactReplacedSecrets(t *testing.T) {
+	setTestConfig(t, &Config{Outputs: []OutputConfig{{StreamKey: "old-key"}}})
+	Set(&Config{Outputs: []OutputConfig{{StreamKey: "new-key"}}})
+	Set(&Config{Outputs: []OutputConfig{{StreamKey: "newer-key"}}})
+
+	// ffmpeg started before the reloads may still log the old keys
+	if got := Redact("old-key new-key newer-key"); got != "****** ****** ******" {
+		t.Errorf("before restart: %q", got)
+	}
+	ForgetReplacedSecrets()
+	if got := Redact("old-key new-key newer-key"); got != "old-key new-key ******" {
+		t.Errorf("after restart: %q", got)
+	}
+}
+
+// setTestConfig runs the test with c as the running config
+func setTestConfig(t *testing.T, c *Config) {
+	old := Get()
+	Set(c)
+	ForgetReplacedSecrets()
+	t.Cleanup(func() {
+		Set(old)
+		ForgetReplacedSecrets()
+	})
+}
@@ -0,0 +1,569 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"live-streamer/constant"
+	"live-streamer/library"
+	"live-streamer/server"
+	"live-streamer/utils"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// command is a console command, it is available on stdin and through ctl
+type command struct {
+	name string
+	args string // usage of the arguments
+	help string
+	run  func(c *console, args []string) error
+}
+
+// console runs a command line, with --json the result is written as
+//
+//	{"ok": true, "result": ...} or {"ok": false, "error": "..."}
+type console struct {
+	stdout  io.Writer
+	stderr  io.Writer
+	json    bool
+	written bool // the result has been written
+}
+
+type consoleResponse struct {
+	OK     bool   `json:"ok"`
+	Result any    `json:"result,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// errUsage makes the usage of the command shown
+var errUsage = errors.New("invalid arguments")
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"help", "[command]", "show the commands or the usage of one", helpCommand},
+		{"status", "", "show what is playing, the mode and the outputs", statusCommand},
+		{"list", "", "list the playlist with the media info", listCommand},
+		{"index", "", "show the index of the playing video", indexCommand},
+		{"current", "", "show the path of the playing video", currentCommand},
+		{"position", "", "show the position in the playing video", positionCommand},
+		{"next", "", "play the next video", nextCommand},
+		{"prev", "", "play the previous video", prevCommand},
+		{"jump", "<index|path>", "play the video at index or path", jumpCommand},
+		{"seek", "<position|+offset|-offset>", "restart the playing video at a position", seekCommand},
+		{"pause", "", "pause streaming", pauseCommand},
+		{"resume", "", "resume streaming", resumeCommand},
+		{"add", "<path>", "add a video to the end of the playlist", addCommand},
+		{"insert", "<index> <path>", "insert a video before index", insertCommand},
+		{"remove", "<index>", "remove the video at index from the playlist", removeCommand},
+		{"move", "<from> <to>", "move a video in the playlist", moveCommand},
+		{"clear", "", "remove every video from the playlist", clearCommand},
+		{"queue", "[add <path>|remove <index>|clear]", "show or edit the up next queue", queueCommand},
+		{"mode", "[" + strings.Join(constant.SupportedPlayModes, "|") + "]", "show or set the play mode", modeCommand},
+		{"schedule", "", "show the programs and when they start next", scheduleCommand},
+		{"failed", "", "show the skipped videos and the bad media", failedCommand},
+		{"outputs", "", "show the outputs", outputsCommand},
+		{"enable", "<output>", "start pushing to an output", enableCommand},
+		{"disable", "<output>", "stop pushing to an output", disableCommand},
+		{"reload", "", "reload the config file", reloadCommand},
+		{"quit", "", "stop streaming and exit", quitCommand},
+	}
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+func input() {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		execCommand(scanner.Text(), os.Stdout, os.Stdout)
+	}
+}
+
+// execCommand runs a console command line, the output goes to stdout and
+// errors go to stderr unless --json is given. It returns false if the
+// command failed.
+func execCommand(line string, stdout, stderr io.Writer) bool {
+	args, err := splitArgs(line)
+	c := &console{stdout: stdout, stderr: stderr}
+	if err != nil {
+		return c.fail(err)
+	}
+	// --json may be given anywhere
+	rest := args[:0]
+	for _, arg := range args {
+		if arg == "--json" {
+			c.json = true
+		} else {
+			rest = append(rest, arg)
+		}
+	}
+	args = rest
+	if len(args) == 0 {
+		return true
+	}
+
+	cmd, ok := findCommand(args[0])
+	if !ok {
+		return c.fail(fmt.Errorf("unknown command: %s, type help to list the commands", args[0]))
+	}
+	if err := cmd.run(c, args[1:]); err != nil {
+		if err == errUsage {
+			err = fmt.Errorf("usage: %s", cmd.usage())
+		}
+		return c.fail(err)
+	}
+	if c.json && !c.written {
+		c.writeJSON(consoleResponse{OK: true})
+	}
+	return true
+}
+
+func (cmd command) usage() string {
+	return strings.TrimSpace(cmd.name + " " + cmd.args)
+}
+
+// result writes v as json or with text
+func (c *console) result(v any, text func(w io.Writer)) {
+	c.written = true
+	if c.json {
+		c.writeJSON(consoleResponse{OK: true, Result: v})
+		return
+	}
+	text(c.stdout)
+}
+
+func (c *console) fail(err error) bool {
+	if c.json {
+		c.writeJSON(consoleResponse{Error: err.Error()})
+	} else {
+		fmt.Fprintln(c.stderr, err)
+	}
+	return false
+}
+
+func (c *console) writeJSON(response consoleResponse) {
+	data, err := json.Marshal(response)
+	if err != nil {
+		data, _ = json.Marshal(consoleResponse{Error: err.Error()})
+	}
+	fmt.Fprintln(c.stdout, string(data))
+}
+
+// splitArgs splits a command line by spaces, quotes keep spaces in an arg
+// and a backslash escapes the next character.
+func splitArgs(line string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			arg.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("unclosed quote")
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+// pathArg joins args into a path, so unquoted paths with spaces work too
+func pathArg(args []string) (string, error) {
+	if len(args) == 0 {
+		return "", errUsage
+	}
+	return strings.Join(args, " "), nil
+}
+
+func indexArg(arg string) (int, error) {
+	index, err := strconv.Atoi(arg)
+	if err != nil {
+		return 0, errUsage
+	}
+	return index, nil
+}
+
+func noArgs(args []string) error {
+	if len(args) > 0 {
+		return errUsage
+	}
+	return nil
+}
+
+type commandHelp struct {
+	Name string `json:"name"`
+	Args string `json:"args,omitempty"`
+	Help string `json:"help"`
+}
+
+func helpCommand(c *console, args []string) error {
+	list := commands
+	if len(args) > 0 {
+		cmd, ok := findCommand(args[0])
+		if !ok {
+			return fmt.Errorf("unknown command: %s", args[0])
+		}
+		list = []command{cmd}
+	}
+	helps := make([]commandHelp, 0, len(list))
+	for _, cmd := range list {
+		helps = append(helps, commandHelp{Name: cmd.name, Args: cmd.args, Help: cmd.help})
+	}
+	c.result(helps, func(w io.Writer) {
+		width := 0
+		for _, cmd := range list {
+			width = max(width, len(cmd.usage()))
+		}
+		for _, cmd := range list {
+			fmt.Fprintf(w, "%-*s  %s\n", width, cmd.usage(), cmd.help)
+		}
+		if len(args) == 0 {
+			fmt.Fprintln(w, "add --json to a command for json output, quote paths with spaces")
+		}
+	})
+	return nil
+}
+
+func statusCommand(c *console, args []string) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	c.result(server.CurrentStatus(), func(w io.Writer) {
+		fmt.Fprintf(w, "current: %s (index %d)\n", GlobalStreamer.GetCurrentVideoPath(), GlobalStreamer.GetCurrentIndex())
+		fmt.Fprintf(w, "position: %s, paused: %v\n", utils.FormatDuration(GlobalStreamer.GetPosition()), GlobalStreamer.IsPaused())
+		fmt.Fprintf(w, "mode: %s, finished: %v\n", GlobalStreamer.GetPlayMode(), GlobalStreamer.IsFinished())
+		for _, output := range GlobalStreamer.GetOutputs() {
+			fmt.Fprintf(w, "output %s: %s (%s), enabled: %v\n", output.Name, output.Server, output.Endpoint, output.Enabled)
+		}
+	})
+	return nil
+}
+
+func listCommand(c *console, args []string) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	items := server.Playlist()
+	c.result(items, func(w io.Writer) {
+		for _, item := range items {
+			fmt.Fprintf(w, "%d\t%s\t%s\n", item.Index, item.Path, describeMedia(item.Media))
+		}
+	})
+	return nil
+}
+
+func indexCommand(c *console, args []string) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	index := GlobalStreamer.GetCurrentIndex()
+	c.result(index, func(w io.Writer) { fmt.Fprintln(w, index) })
+	return nil
+}
+
+func currentCommand(c *console, args []string) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	path := GlobalStreamer.GetCurrentVideoPath()
+	c.result(path, func(w io.Writer) { fmt.Fprintln(w, path) })
+	return nil
+}
+
+func positionCommand(c *console, args []string) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	position := GlobalStreamer.GetPosition()
+	c.result(position.Seconds(), func(w io.Writer) { fmt.Fprintln(w, utils.FormatDuration(position)) })
+	return nil
+}
+
+func nextCommand(c *console, args []string) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	GlobalStreamer.Next()
+	return nil
+}
+
+func prevCommand(c *console, args []string) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	GlobalStreamer.Prev()
+	return nil
+}
+
+func jumpCommand(c *console, args []string) error {
+	target, err := pathArg(args)
+	if err != nil {
+		return err
+	}
+	if index, err := strconv.Atoi(target); err == nil {
+		return GlobalStreamer.Jump(index)
+	}
+	return GlobalStreamer.JumpToPath(target)
+}
+
+func seekCommand(c *console, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	return GlobalStreamer.Seek(args[0])
+}
+
+func pauseCommand(c *console, args []string) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	return GlobalStreamer.Pause()
+}
+
+func resumeCommand(c *console, args []string) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	return GlobalStreamer.Resume()
+}
+
+func addCommand(c *console, args []string) error {
+	path, err := pathArg(args)
+	if err != nil {
+		return err
+	}
+	return GlobalStreamer.Append(path)
+}
+
+func insertCommand(c *console, args []string) error {
+	if len(args) < 2 {
+		return errUsage
+	}
+	index, err := indexArg(args[0])
+	if err != nil {
+		return err
+	}
+	path, err := pathArg(args[1:])
+	if err != nil {
+		return err
+	}
+	return GlobalStreamer.Insert(index, path)
+}
+
+func removeCommand(c *console, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	index, err := indexArg(args[0])
+	if err != nil {
+		return err
+	}
+	return GlobalStreamer.RemoveAt(index)
+}
+
+func moveCommand(c *console, args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+	from, err := indexArg(args[0])
+	if err != nil {
+		return err
+	}
+	to, err := indexArg(args[1])
+	if err != nil {
+		return err
+	}
+	return GlobalStreamer.Move(from, to)
+}
+
+func clearCommand(c *console, args []string) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	return GlobalStreamer.Clear()
+}
+
+// queueCommand handles "queue", "queue add <path>", "queue remove <index>"
+// and "queue clear".
+func queueCommand(c *console, args []string) error {
+	if len(args) == 0 {
+		queue := GlobalStreamer.GetQueue()
+		c.result(queue, func(w io.Writer) {
+			for i, path := range queue {
+				fmt.Fprintf(w, "%d\t%s\n", i, path)
+			}
+		})
+		return nil
+	}
+	switch args[0] {
+	case "add":
+		path, err := pathArg(args[1:])
+		if err != nil {
+			return err
+		}
+		return GlobalStreamer.Enqueue(path)
+	case "remove":
+		if len(args) != 2 {
+			return errUsage
+		}
+		index, err := indexArg(args[1])
+		if err != nil {
+			return err
+		}
+		return GlobalStreamer.RemoveFromQueue(index)
+	case "clear":
+		if len(args) != 1 {
+			return errUsage
+		}
+		return GlobalStreamer.ClearQueue()
+	}
+	return errUsage
+}
+
+func modeCommand(c *console, args []string) error {
+	switch len(args) {
+	case 0:
+		mode := GlobalStreamer.GetPlayMode()
+		c.result(mode, func(w io.Writer) { fmt.Fprintln(w, mode) })
+		return nil
+	case 1:
+		return GlobalStreamer.SetPlayMode(args[0])
+	}
+	return errUsage
+}
+
+func scheduleCommand(c *console, args []string) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	schedules := GlobalStreamer.GetSchedule()
+	c.result(schedules, func(w io.Writer) {
+		for _, schedule := range schedules {
+			fmt.Fprintf(w, "%s\tat %s %v\thard start: %v\tnext: %s\n", schedule.Name, schedule.At, schedule.Days,
+				schedule.HardStart, time.UnixMilli(schedule.Next).Format(time.DateTime))
+		}
+	})
+	return nil
+}
+
+func failedCommand(c *console, args []string) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	failed := GlobalStreamer.GetFailedVideos()
+	bad := library.GlobalLibrary.BadMedia()
+	c.result(map[string]map[string]string{"failed": failed, "bad": bad}, func(w io.Writer) {
+		for path, reason := range failed {
+			fmt.Fprintf(w, "%s\t%s\n", path, reason)
+		}
+		for path, reason := range bad {
+			fmt.Fprintf(w, "%s\tbad media: %s\n", path, reason)
+		}
+	})
+	return nil
+}
+
+func outputsCommand(c *console, args []string) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	outputs := GlobalStreamer.GetOutputs()
+	c.result(outputs, func(w io.Writer) {
+		for _, output := range outputs {
+			fmt.Fprintf(w, "%s\t%s\t%s\tenabled: %v\n", output.Name, output.Server, output.Endpoint, output.Enabled)
+		}
+	})
+	return nil
+}
+
+func enableCommand(c *console, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	return GlobalStreamer.SetOutputEnabled(args[0], true)
+}
+
+func disableCommand(c *console, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	return GlobalStreamer.SetOutputEnabled(args[0], false)
+}
+
+func reloadCommand(c *console, args []string) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	return reloadConfig()
+}
+
+func quitCommand(c *console, args []string) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	quit()
+	return nil
+}
+
+// describeMedia returns a short summary of the media info
+func describeMedia(info *library.MediaInfo) string {
+	if info == nil {
+		return "not probed yet"
+	}
+	return formatMedia(*info)
+}
+
+func formatMedia(info library.MediaInfo) string {
+	if info.Error != "" {
+		return info.Error
+	}
+	audio := make([]string, 0, len(info.AudioTracks))
+	for _, track := range info.AudioTracks {
+		audio = append(audio, track.Codec)
+	}
+	return fmt.Sprintf("%s\t%dx%d %.2ffps\t%s/%s",
+		utils.FormatDuration(time.Duration(info.Duration*float64(time.Second))),
+		info.Width, info.Height, info.FrameRate,
+		info.VideoCodec, strings.Join(audio, ","))
+}
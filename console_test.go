@@ -0,0 +1,79 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    []string
+		wantErr bool
+	}{
+		{"next", []string{"next"}, false},
+		{"  jump   3 ", []string{"jump", "3"}, false},
+		{"queue add ./my video.mp4\n", []string{"queue", "add", "./my", "video.mp4"}, false},
+		{`queue add "./my video.mp4"`, []string{"queue", "add", "./my video.mp4"}, false},
+		{`queue add './my video.mp4'`, []string{"queue", "add", "./my video.mp4"}, false},
+		{`queue add ./my\ video.mp4`, []string{"queue", "add", "./my video.mp4"}, false},
+		{`say "it's \"ok\""`, []string{"say", `it's "ok"`}, false},
+		{`say 'a\b'`, []string{"say", `a\b`}, false},
+		{`say a"b c"d`, []string{"say", "ab cd"}, false},
+		{`say ""`, []string{"say", ""}, false},
+		{"say\ta\tb", []string{"say", "a", "b"}, false},
+		{"", nil, false},
+		{`say "unclosed`, nil, true},
+		{`say 'unclosed`, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := splitArgs(tt.line)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("splitArgs(%q) error = %v, want error %v", tt.line, err, tt.wantErr)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("splitArgs(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestQuoteArgRoundTrip(t *testing.T) {
+	args := []string{
+		"next",
+		"",
+		"./my video.mp4",
+		`it's`,
+		`say "hi"`,
+		`C:\videos\a.mp4`,
+		`trailing\`,
+		"tab\there",
+		"no\u00a0break",
+		"视频 01.mp4",
+	}
+	for _, arg := range args {
+		quoted := quoteArg(arg)
+		got, err := splitArgs(quoted)
+		if err != nil {
+			t.Errorf("splitArgs(quoteArg(%q)) error: %v", arg, err)
+			continue
+		}
+		if len(got) != 1 || got[0] != arg {
+			t.Errorf("splitArgs(quoteArg(%q)) = %q, quoted as %s", arg, got, quoted)
+		}
+	}
+
+	// every arg of a command line keeps its place
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		quoted = append(quoted, quoteArg(arg))
+	}
+	got, err := splitArgs(strings.Join(quoted, " "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, args) {
+		t.Errorf("round trip = %q, want %q", got, args)
+	}
+}
@@ -15,3 +15,27 @@ var SupportedStreamingFormats = []string{
 	"rtmp",
 	"srt",
 }
+
+var SupportedImageFormats = []string{
+	"png",
+	"jpg",
+	"jpeg",
+	"bmp",
+	"webp",
+}
+
+const (
+	PlayModeSequential = "sequential"
+	PlayModeShuffle    = "shuffle"    // every video once in a random order, then again
+	PlayModeRandom     = "random"     // any video, repeats are possible
+	PlayModeRepeatOne  = "repeat-one" // the current video over and over
+	PlayModeOnce       = "once"       // stop after the last video
+)
+
+var SupportedPlayModes = []string{
+	PlayModeSequential,
+	PlayModeShuffle,
+	PlayModeRandom,
+	PlayModeRepeatOne,
+	PlayModeOnce,
+}
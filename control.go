@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"live-streamer/config"
+	"log"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// controlResponse is what the control socket answers to a command, with the
+// stdout and stderr of the command.
+type controlResponse struct {
+	OK     bool   `json:"ok"`
+	Output string `json:"output,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+const controlTimeout = 10 * time.Second
+
+var controlListener net.Listener
+
+// listenControl accepts console commands on the control socket, one command
+// per connection.
+func listenControl(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			conn.Close()
+			return fmt.Errorf("control socket %s is in use by another instance", path)
+		}
+		// left behind by a crashed instance
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("remove stale control socket failed: %v", err)
+		}
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("listen on control socket failed: %v", err)
+	}
+	// the socket gives full control over the stream, keep it to the owner
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return fmt.Errorf("chmod control socket failed: %v", err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					log.Printf("control socket accept error: %v", err)
+				}
+				return
+			}
+			go serveControl(conn)
+		}
+	}()
+	controlListener = listener
+	log.Println("control socket:", path)
+	return nil
+}
+
+func serveControl(conn net.Conn) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(controlTimeout))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return
+	}
+	if args, _ := splitArgs(line); len(args) > 0 && args[0] == "quit" {
+		// answer before the process exits
+		_ = json.NewEncoder(conn).Encode(controlResponse{OK: true})
+		conn.Close()
+		quit()
+	}
+	var stdout, stderr bytes.Buffer
+	ok := execCommand(line, &stdout, &stderr)
+	_ = json.NewEncoder(conn).Encode(controlResponse{OK: ok, Output: stdout.String(), Error: stderr.String()})
+}
+
+// quit removes the control socket and stops streaming
+func quit() {
+	if controlListener != nil {
+		controlListener.Close()
+	}
+	GlobalStreamer.Close()
+}
+
+// controlSocketPath returns the --socket flag, or the control socket of the
+// config when it can be loaded.
+func controlSocketPath() string {
+	if socketPath != "" {
+		return socketPath
+	}
+	if c, err := loadConfig(); err == nil {
+		return c.ControlSocket
+	}
+	return config.DefaultControlSocket
+}
+
+// ctlCommand sends a console command to the running instance
+func ctlCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: live-streamer ctl <command> [args]")
+		return 2
+	}
+	// the command is sent as a single line
+	if slices.ContainsFunc(args, func(arg string) bool { return strings.ContainsAny(arg, "\r\n") }) {
+		fmt.Fprintln(os.Stderr, "args can't contain a newline")
+		return 2
+	}
+	path := controlSocketPath()
+	conn, err := net.DialTimeout("unix", path, controlTimeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "connect to %s failed, is live-streamer running? %v\n", path, err)
+		return 1
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(controlTimeout))
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		quoted = append(quoted, quoteArg(arg))
+	}
+	if _, err := fmt.Fprintln(conn, strings.Join(quoted, " ")); err != nil {
+		fmt.Fprintf(os.Stderr, "send command failed: %v\n", err)
+		return 1
+	}
+	var response controlResponse
+	if err := json.NewDecoder(conn).Decode(&response); err != nil {
+		fmt.Fprintf(os.Stderr, "read response failed: %v\n", err)
+		return 1
+	}
+	fmt.Print(response.Output)
+	fmt.Fprint(os.Stderr, response.Error)
+	if !response.OK {
+		return 1
+	}
+	return 0
+}
+
+// quoteArg quotes arg so splitArgs returns it as it is
+func quoteArg(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, "\"'\\") && !strings.ContainsFunc(arg, unicode.IsSpace) {
+		return arg
+	}
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	return `"` + replacer.Replace(arg) + `"`
+}
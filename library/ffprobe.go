@@ -0,0 +1,132 @@
+package library
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+type ffprobeOutput struct {
+	Streams []struct {
+		CodecType    string            `json:"codec_type"`
+		CodecName    string            `json:"codec_name"`
+		Width        int               `json:"width"`
+		Height       int               `json:"height"`
+		AvgFrameRate string            `json:"avg_frame_rate"`
+		Channels     int               `json:"channels"`
+		Tags         map[string]string `json:"tags"`
+	} `json:"streams"`
+	Format struct {
+		FormatName string `json:"format_name"`
+		Duration   string `json:"duration"`
+		BitRate    string `json:"bit_rate"`
+	} `json:"format"`
+}
+
+// Probe probes and checks the video at path without using the cache, why
+// it can't be played is set as the Error of the result.
+func Probe(path string) MediaInfo {
+	info, err := probe(path)
+	if err == nil {
+		err = check(path, info)
+	}
+	if err != nil {
+		info.Error = err.Error()
+	}
+	return info
+}
+
+// probe runs ffprobe on the video at path
+func probe(path string) (MediaInfo, error) {
+	out, err := exec.Command("ffprobe",
+		"-v", "error",
+		"-print_format", "json",
+		"-show_format",
+		"-show_streams",
+		path,
+	).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return MediaInfo{}, fmt.Errorf("ffprobe failed: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return MediaInfo{}, fmt.Errorf("ffprobe failed: %v", err)
+	}
+	var output ffprobeOutput
+	if err := json.Unmarshal(out, &output); err != nil {
+		return MediaInfo{}, fmt.Errorf("ffprobe output is invalid: %v", err)
+	}
+
+	info := MediaInfo{Format: output.Format.FormatName}
+	info.Duration, _ = strconv.ParseFloat(output.Format.Duration, 64)
+	info.Bitrate, _ = strconv.ParseInt(output.Format.BitRate, 10, 64)
+	for _, stream := range output.Streams {
+		switch stream.CodecType {
+		case "video":
+			// the first video stream is the one ffmpeg maps
+			if info.VideoCodec == "" {
+				info.VideoCodec = stream.CodecName
+				info.Width = stream.Width
+				info.Height = stream.Height
+				info.FrameRate = parseFrameRate(stream.AvgFrameRate)
+			}
+		case "audio":
+			info.AudioTracks = append(info.AudioTracks, AudioTrack{
+				Codec:    stream.CodecName,
+				Channels: stream.Channels,
+				Language: stream.Tags["language"],
+			})
+		}
+	}
+	return info, nil
+}
+
+// how much of a video check decodes
+const checkDuration = "5"
+
+// check decodes the beginning of the video at path to find files ffprobe
+// accepts but ffmpeg can't play. ffmpeg goes on after most decoding errors,
+// so any error it logs fails the check.
+func check(path string, info MediaInfo) error {
+	if info.VideoCodec == "" {
+		return errors.New("no video stream")
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command("ffmpeg",
+		"-v", "error",
+		"-xerror",
+		"-t", checkDuration,
+		"-i", path,
+		"-map", "0:v:0",
+		"-f", "null", "-",
+	)
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if line, _, _ := strings.Cut(strings.TrimSpace(stderr.String()), "\n"); line != "" {
+		return fmt.Errorf("decoding failed: %s", line)
+	}
+	if err != nil {
+		return fmt.Errorf("decoding failed: %v", err)
+	}
+	return nil
+}
+
+// parseFrameRate parses ffprobe frame rates like "30000/1001"
+func parseFrameRate(rate string) float64 {
+	num, den, found := strings.Cut(rate, "/")
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0
+	}
+	if !found {
+		return n
+	}
+	d, err := strconv.ParseFloat(den, 64)
+	if err != nil || d == 0 {
+		return 0
+	}
+	return n / d
+}
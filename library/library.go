@@ -0,0 +1,195 @@
+package library
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+)
+
+type AudioTrack struct {
+	Codec    string `json:"codec"`
+	Channels int    `json:"channels"`
+	Language string `json:"language,omitempty"`
+}
+
+// MediaInfo is what ffprobe found out about a video
+type MediaInfo struct {
+	Duration    float64      `json:"duration"` // seconds
+	Format      string       `json:"format"`
+	Bitrate     int64        `json:"bitrate"` // bit/s
+	VideoCodec  string       `json:"videoCodec"`
+	Width       int          `json:"width"`
+	Height      int          `json:"height"`
+	FrameRate   float64      `json:"frameRate"`
+	AudioTracks []AudioTrack `json:"audioTracks"`
+	Error       string       `json:"error,omitempty"` // why the video can't be played
+}
+
+// entry is a probed video, it is probed again when the file changes
+type entry struct {
+	ModTime int64     `json:"modTime"` // unix nanoseconds
+	Size    int64     `json:"size"`
+	Checked bool      `json:"checked"` // the decode check ran
+	Info    MediaInfo `json:"info"`
+}
+
+type Library struct {
+	cacheFile string
+	mu        sync.RWMutex
+	entries   map[string]entry
+	checking  map[string]bool // videos changed on disk being checked again
+	saveMu    sync.Mutex
+}
+
+var GlobalLibrary *Library
+
+// NewLibrary creates the library and loads the probe results cached in
+// cacheFile by the last run.
+func NewLibrary(cacheFile string) *Library {
+	GlobalLibrary = &Library{
+		cacheFile: cacheFile,
+		entries:   make(map[string]entry),
+		checking:  make(map[string]bool),
+	}
+	GlobalLibrary.load()
+	return GlobalLibrary
+}
+
+// Get returns the media info of the video at path, it is probed and checked
+// unless the cached result is still valid.
+func (l *Library) Get(path string) (MediaInfo, error) {
+	info, changed, err := l.get(path)
+	if changed {
+		l.save()
+	}
+	return info, err
+}
+
+func (l *Library) get(path string) (MediaInfo, bool, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return MediaInfo{}, false, err
+	}
+	l.mu.RLock()
+	cached, ok := l.entries[path]
+	l.mu.RUnlock()
+	if ok && cached.Checked && unchanged(cached, stat) {
+		return cached.Info, false, nil
+	}
+
+	// a broken file is remembered so it isn't checked over and over
+	info := Probe(path)
+	l.mu.Lock()
+	l.entries[path] = entry{ModTime: stat.ModTime().UnixNano(), Size: stat.Size(), Checked: true, Info: info}
+	l.mu.Unlock()
+	return info, true, nil
+}
+
+func unchanged(cached entry, stat os.FileInfo) bool {
+	return cached.ModTime == stat.ModTime().UnixNano() && cached.Size == stat.Size()
+}
+
+// Bad returns why the video at path can't be played, videos that haven't
+// been checked yet are assumed to be fine. A bad video that changed on disk
+// is checked again in the background and stays bad until then.
+func (l *Library) Bad(path string) (string, bool) {
+	l.mu.RLock()
+	cached, ok := l.entries[path]
+	l.mu.RUnlock()
+	if !ok || cached.Info.Error == "" {
+		return "", false
+	}
+	if stat, err := os.Stat(path); err == nil && !unchanged(cached, stat) {
+		l.mu.Lock()
+		if !l.checking[path] {
+			l.checking[path] = true
+			go func() {
+				l.Get(path)
+				l.mu.Lock()
+				delete(l.checking, path)
+				l.mu.Unlock()
+			}()
+		}
+		l.mu.Unlock()
+	}
+	return cached.Info.Error, true
+}
+
+// BadMedia returns the videos that can't be played with the reason
+func (l *Library) BadMedia() map[string]string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	bad := make(map[string]string)
+	for path, cached := range l.entries {
+		if cached.Info.Error != "" {
+			bad[path] = cached.Info.Error
+		}
+	}
+	return bad
+}
+
+// Lookup returns the cached media info of the video at path without probing
+func (l *Library) Lookup(path string) (MediaInfo, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	cached, ok := l.entries[path]
+	return cached.Info, ok
+}
+
+// Scan probes the videos that haven't been probed yet or changed since
+func (l *Library) Scan(paths []string) {
+	changed := false
+	for _, path := range paths {
+		_, probed, err := l.get(path)
+		if err != nil {
+			log.Printf("probing %s error: %v", path, err)
+		}
+		changed = changed || probed
+	}
+	if changed {
+		l.save()
+	}
+}
+
+// Forget drops the cached result of the video at path
+func (l *Library) Forget(path string) {
+	l.mu.Lock()
+	delete(l.entries, path)
+	l.mu.Unlock()
+}
+
+func (l *Library) load() {
+	data, err := os.ReadFile(l.cacheFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("reading media cache error: %v", err)
+		}
+		return
+	}
+	if err := json.Unmarshal(data, &l.entries); err != nil {
+		log.Printf("media cache is invalid, ignored: %v", err)
+		l.entries = make(map[string]entry)
+	}
+}
+
+func (l *Library) save() {
+	l.saveMu.Lock()
+	defer l.saveMu.Unlock()
+	l.mu.RLock()
+	data, err := json.Marshal(l.entries)
+	l.mu.RUnlock()
+	if err != nil {
+		log.Printf("marshal media cache error: %v", err)
+		return
+	}
+	// write to a temp file first so a crash never leaves a truncated cache
+	tmp := l.cacheFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		log.Printf("writing media cache error: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, l.cacheFile); err != nil {
+		log.Printf("writing media cache error: %v", err)
+	}
+}
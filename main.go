@@ -1,11 +1,11 @@
 package main
 
 import (
-	"bufio"
 	"fmt"
 
 	"live-streamer/config"
 	"live-streamer/constant"
+	"live-streamer/library"
 	"live-streamer/server"
 	"live-streamer/streamer"
 	"live-streamer/utils"
@@ -19,38 +19,61 @@ import (
 var GlobalStreamer *streamer.Streamer
 
 func main() {
-	fmt.Println("Version: " + constant.Version)
-	server.NewServer(config.GlobalConfig.Server.Addr, websocket.RequestHandler)
+	command, args := parseCommandLine()
+	switch command {
+	case "run":
+		runCommand(args)
+	case "validate":
+		os.Exit(validateCommand())
+	case "probe":
+		os.Exit(probeCommand(args))
+	case "ctl":
+		os.Exit(ctlCommand(args))
+	case "version":
+		fmt.Println(constant.Version)
+	case "help":
+		usage()
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n\n", command)
+		usage()
+		os.Exit(2)
+	}
+}
+
+// run streams until quit
+func run() {
+	server.NewServer(config.Get().Server.Addr, websocket.RequestHandler)
 	server.GlobalServer.Run()
 	if !utils.HasFFMPEG() {
 		log.Fatal("ffmpeg not found")
 	}
-	GlobalStreamer = streamer.NewStreamer(config.GlobalConfig.VideoList)
+	library.NewLibrary(config.Get().MediaCache)
+	GlobalStreamer = streamer.NewStreamer(config.Get().VideoList)
+	go library.GlobalLibrary.Scan(libraryPaths())
 	go startWatcher()
+	go watchConfig()
 	go input()
+	if err := listenControl(config.Get().ControlSocket); err != nil {
+		log.Println(err)
+	}
 	GlobalStreamer.Stream()
-	GlobalStreamer.Close()
+	quit()
 }
 
-func input() {
-	scanner := bufio.NewScanner(os.Stdin)
-	for scanner.Scan() {
-		line := scanner.Text()
-		switch line {
-		case "list":
-			fmt.Println(GlobalStreamer.GetVideoListPath())
-		case "index":
-			fmt.Println(GlobalStreamer.GetCurrentIndex())
-		case "next":
-			GlobalStreamer.Next()
-		case "prev":
-			GlobalStreamer.Prev()
-		case "quit":
-			GlobalStreamer.Close()
-		case "current":
-			fmt.Println(GlobalStreamer.GetCurrentVideoPath())
+// libraryPaths returns the videos scanned at startup, the scheduled ones
+// included so the program guide knows their duration.
+func libraryPaths() []string {
+	paths := GlobalStreamer.GetVideoListPath()
+	for _, schedule := range config.Get().Schedule {
+		videos, err := schedule.Videos()
+		if err != nil {
+			continue
+		}
+		for _, item := range videos {
+			paths = append(paths, item.Path)
 		}
 	}
+	return paths
 }
 
 func startWatcher() {
@@ -59,17 +82,11 @@ func startWatcher() {
 		log.Fatalf("failed to create watcher: %v", err)
 	}
 	defer watcher.Close()
-	for _, item := range config.GlobalConfig.InputItems {
-		if item.ItemType == "dir" {
-			err = watcher.Add(item.Path)
-			if err != nil {
-				log.Fatalf("failed to add dir to watcher: %v", err)
-			}
-			log.Println("watching dir:", item.Path)
-		}
-	}
-	if err != nil {
-		log.Fatalf("failed to start watcher: %v", err)
+	watchedMu.Lock()
+	inputWatcher = watcher
+	watchedMu.Unlock()
+	if err := watchInputDirs(config.Get().InputItems); err != nil {
+		log.Fatal(err)
 	}
 
 	for {
@@ -87,6 +104,7 @@ func startWatcher() {
 			if event.Op&fsnotify.Remove == fsnotify.Remove {
 				log.Println("video removed:", event.Name)
 				GlobalStreamer.Remove(event.Name)
+				library.GlobalLibrary.Forget(event.Name)
 			}
 		case err, ok := <-watcher.Errors:
 			if !ok {
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"live-streamer/config"
+	"log"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// editors write a file in several steps, reload once they are done
+const configReloadDelay = 500 * time.Millisecond
+
+var (
+	reloadMu sync.Mutex
+
+	inputWatcher *fsnotify.Watcher
+	watchedDirs  = make(map[string]bool)
+	watchedMu    sync.Mutex
+)
+
+// reloadConfig loads the config file again and applies it, an invalid config
+// is rejected and the running one is kept.
+func reloadConfig() error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	c, err := loadConfig()
+	if err != nil {
+		GlobalStreamer.ReportConfigError(err)
+		return err
+	}
+	old := config.Get()
+	config.Set(c)
+	if err := watchInputDirs(c.InputItems); err != nil {
+		log.Println(err)
+	}
+	GlobalStreamer.ApplyConfig(old, c)
+	return nil
+}
+
+// watchConfig reloads the config whenever the file changes. The directory is
+// watched since editors often replace the file instead of writing to it.
+func watchConfig() {
+	path, err := filepath.Abs(configPath)
+	if err != nil {
+		log.Printf("failed to watch config: %v", err)
+		return
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("failed to watch config: %v", err)
+		return
+	}
+	defer watcher.Close()
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		log.Printf("failed to watch config: %v", err)
+		return
+	}
+	log.Println("watching config:", path)
+
+	var timer *time.Timer
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) != path ||
+				!event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) {
+				continue
+			}
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.AfterFunc(configReloadDelay, func() {
+				log.Println("config changed, reloading")
+				_ = reloadConfig()
+			})
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Println("config watcher error:", err)
+		}
+	}
+}
+
+// watchInputDirs makes the input watcher watch the dirs of items only
+func watchInputDirs(items []config.InputItem) error {
+	watchedMu.Lock()
+	defer watchedMu.Unlock()
+	if inputWatcher == nil {
+		return nil
+	}
+	dirs := make(map[string]bool)
+	for _, item := range items {
+		if item.ItemType == "dir" {
+			dirs[item.Path] = true
+		}
+	}
+	for dir := range watchedDirs {
+		if !dirs[dir] {
+			_ = inputWatcher.Remove(dir)
+			delete(watchedDirs, dir)
+			log.Println("stop watching dir:", dir)
+		}
+	}
+	for dir := range dirs {
+		if watchedDirs[dir] {
+			continue
+		}
+		if err := inputWatcher.Add(dir); err != nil {
+			return fmt.Errorf("failed to add dir to watcher: %v", err)
+		}
+		watchedDirs[dir] = true
+		log.Println("watching dir:", dir)
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"live-streamer/library"
+	"live-streamer/streamer"
+	mywebsocket "live-streamer/websocket"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIResponse is returned by the control endpoints that don't return data
+type APIResponse struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+type PlaylistItem struct {
+	Index   int                `json:"index"`
+	Path    string             `json:"path"`
+	Playing bool               `json:"playing"`
+	Failed  string             `json:"failed,omitempty"` // reason the video is skipped
+	Bad     string             `json:"bad,omitempty"`    // reason the video failed the check
+	Media   *library.MediaInfo `json:"media,omitempty"`  // absent until the video is probed
+}
+
+// registerAPI registers the REST control api, it offers the same controls as
+// the websocket for scripts that don't want to keep a connection open.
+func registerAPI(api *gin.RouterGroup) {
+	api.GET("/status", handleStatus)
+
+	api.GET("/playlist", handleGetPlaylist)
+	api.POST("/playlist", handleAction(func(c *gin.Context) error {
+		var payload mywebsocket.InsertVideoPayload
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			return err
+		}
+		if payload.Index == nil {
+			return streamer.GlobalStreamer.Append(payload.Path)
+		}
+		return streamer.GlobalStreamer.Insert(*payload.Index, payload.Path)
+	}))
+	api.DELETE("/playlist", handleAction(removeVideo))
+	api.DELETE("/playlist/:index", handleAction(func(c *gin.Context) error {
+		index, err := strconv.Atoi(c.Param("index"))
+		if err != nil {
+			return fmt.Errorf("invalid index: %s", c.Param("index"))
+		}
+		return streamer.GlobalStreamer.RemoveAt(index)
+	}))
+	api.POST("/playlist/move", handleAction(func(c *gin.Context) error {
+		var payload mywebsocket.MoveVideoPayload
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			return err
+		}
+		return streamer.GlobalStreamer.Move(payload.From, payload.To)
+	}))
+	api.POST("/playlist/clear", handleAction(func(*gin.Context) error {
+		return streamer.GlobalStreamer.Clear()
+	}))
+
+	api.GET("/bad-media", func(c *gin.Context) {
+		c.JSON(http.StatusOK, library.GlobalLibrary.BadMedia())
+	})
+
+	api.GET("/queue", func(c *gin.Context) {
+		c.JSON(http.StatusOK, streamer.GlobalStreamer.GetQueue())
+	})
+	api.POST("/queue", handleAction(func(c *gin.Context) error {
+		var payload mywebsocket.EnqueueVideoPayload
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			return err
+		}
+		return streamer.GlobalStreamer.Enqueue(payload.Path)
+	}))
+	api.DELETE("/queue/:index", handleAction(func(c *gin.Context) error {
+		index, err := strconv.Atoi(c.Param("index"))
+		if err != nil {
+			return fmt.Errorf("invalid index: %s", c.Param("index"))
+		}
+		return streamer.GlobalStreamer.RemoveFromQueue(index)
+	}))
+	api.POST("/queue/clear", handleAction(func(*gin.Context) error {
+		return streamer.GlobalStreamer.ClearQueue()
+	}))
+
+	api.GET("/schedule", func(c *gin.Context) {
+		c.JSON(http.StatusOK, streamer.GlobalStreamer.GetSchedule())
+	})
+	api.GET("/epg", handleEPG)
+	api.GET("/epg.xml", handleXMLTV)
+
+	api.POST("/next", handleAction(func(*gin.Context) error {
+		streamer.GlobalStreamer.Next()
+		return nil
+	}))
+	api.POST("/prev", handleAction(func(*gin.Context) error {
+		streamer.GlobalStreamer.Prev()
+		return nil
+	}))
+	api.POST("/jump", handleAction(func(c *gin.Context) error {
+		var payload mywebsocket.JumpPayload
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			return err
+		}
+		if payload.Index != nil {
+			return streamer.GlobalStreamer.Jump(*payload.Index)
+		}
+		return streamer.GlobalStreamer.JumpToPath(payload.Path)
+	}))
+	api.POST("/pause", handleAction(func(*gin.Context) error {
+		return streamer.GlobalStreamer.Pause()
+	}))
+	api.POST("/resume", handleAction(func(*gin.Context) error {
+		return streamer.GlobalStreamer.Resume()
+	}))
+	api.POST("/seek", handleAction(func(c *gin.Context) error {
+		var payload mywebsocket.SeekPayload
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			return err
+		}
+		return streamer.GlobalStreamer.Seek(payload.Position)
+	}))
+
+	api.PUT("/mode", handleAction(func(c *gin.Context) error {
+		var payload mywebsocket.SetPlayModePayload
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			return err
+		}
+		return streamer.GlobalStreamer.SetPlayMode(payload.Mode)
+	}))
+
+	api.GET("/outputs", func(c *gin.Context) {
+		c.JSON(http.StatusOK, streamer.GlobalStreamer.GetOutputs())
+	})
+	api.PUT("/outputs/:name", handleAction(func(c *gin.Context) error {
+		var payload mywebsocket.SetOutputEnabledPayload
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			return err
+		}
+		return streamer.GlobalStreamer.SetOutputEnabled(c.Param("name"), payload.Enabled)
+	}))
+}
+
+// handleAction runs action and replies with an APIResponse
+func handleAction(action func(*gin.Context) error) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := action(c); err != nil {
+			c.JSON(http.StatusBadRequest, APIResponse{OK: false, Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, APIResponse{OK: true})
+	}
+}
+
+func handleStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, CurrentStatus())
+}
+
+func handleGetPlaylist(c *gin.Context) {
+	c.JSON(http.StatusOK, Playlist())
+}
+
+// Playlist returns the playlist with the state and media info of each video
+func Playlist() []PlaylistItem {
+	current := streamer.GlobalStreamer.GetCurrentIndex()
+	failed := streamer.GlobalStreamer.GetFailedVideos()
+	items := []PlaylistItem{}
+	for i, path := range streamer.GlobalStreamer.GetVideoListPath() {
+		item := PlaylistItem{
+			Index:   i,
+			Path:    path,
+			Playing: i == current,
+			Failed:  failed[path],
+		}
+		if info, ok := library.GlobalLibrary.Lookup(path); ok {
+			item.Media = &info
+			item.Bad = info.Error
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+func removeVideo(c *gin.Context) error {
+	path := c.Query("path")
+	if path == "" {
+		return errors.New("path is required")
+	}
+	if !slices.Contains(streamer.GlobalStreamer.GetVideoListPath(), path) {
+		return fmt.Errorf("video %s not found", path)
+	}
+	streamer.GlobalStreamer.Remove(path)
+	return nil
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"encoding/xml"
+	"fmt"
+	"live-streamer/config"
+	"live-streamer/constant"
+	"live-streamer/streamer"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// the guide can't be projected further than a week
+const maxGuideHours = 7 * 24
+
+type EPGChannel struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type EPGResponse struct {
+	Channel    EPGChannel           `json:"channel"`
+	Programmes []streamer.Programme `json:"programmes"`
+}
+
+type xmltvTV struct {
+	XMLName       xml.Name         `xml:"tv"`
+	GeneratorName string           `xml:"generator-info-name,attr"`
+	Channels      []xmltvChannel   `xml:"channel"`
+	Programmes    []xmltvProgramme `xml:"programme"`
+}
+
+type xmltvChannel struct {
+	ID          string `xml:"id,attr"`
+	DisplayName string `xml:"display-name"`
+}
+
+type xmltvProgramme struct {
+	Start    string `xml:"start,attr"`
+	Stop     string `xml:"stop,attr"`
+	Channel  string `xml:"channel,attr"`
+	Title    string `xml:"title"`
+	Category string `xml:"category,omitempty"`
+}
+
+const xmltvTimeFormat = "20060102150405 -0700"
+
+// guideHorizon returns how far ahead the guide is projected, the hours query
+// parameter overrides epg.hours.
+func guideHorizon(c *gin.Context) (time.Duration, error) {
+	hours := config.Get().EPG.Hours
+	if query := c.Query("hours"); query != "" {
+		var err error
+		hours, err = strconv.Atoi(query)
+		if err != nil || hours <= 0 || hours > maxGuideHours {
+			return 0, fmt.Errorf("hours must be between 1 and %d", maxGuideHours)
+		}
+	}
+	return time.Duration(hours) * time.Hour, nil
+}
+
+func handleEPG(c *gin.Context) {
+	horizon, err := guideHorizon(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{OK: false, Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, EPGResponse{
+		Channel: EPGChannel{
+			ID:   config.Get().EPG.ChannelID,
+			Name: config.Get().EPG.ChannelName,
+		},
+		Programmes: streamer.GlobalStreamer.GetGuide(horizon),
+	})
+}
+
+func handleXMLTV(c *gin.Context) {
+	horizon, err := guideHorizon(c)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	channelID := config.Get().EPG.ChannelID
+	tv := xmltvTV{
+		GeneratorName: "live-streamer " + constant.Version,
+		Channels: []xmltvChannel{{
+			ID:          channelID,
+			DisplayName: config.Get().EPG.ChannelName,
+		}},
+	}
+	for _, programme := range streamer.GlobalStreamer.GetGuide(horizon) {
+		tv.Programmes = append(tv.Programmes, xmltvProgramme{
+			Start:    time.UnixMilli(programme.Start).Format(xmltvTimeFormat),
+			Stop:     time.UnixMilli(programme.Stop).Format(xmltvTimeFormat),
+			Channel:  channelID,
+			Title:    programme.Title,
+			Category: programme.Schedule,
+		})
+	}
+	data, err := xml.MarshalIndent(tv, "", "  ")
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.Data(http.StatusOK, "application/xml; charset=utf-8", append([]byte(xml.Header), data...))
+}
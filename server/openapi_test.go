@@ -0,0 +1,159 @@
+package server
+
+import (
+	"encoding/json"
+	"live-streamer/library"
+	"live-streamer/streamer"
+	mywebsocket "live-streamer/websocket"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type openAPISpec struct {
+	Servers []struct {
+		URL string `json:"url"`
+	} `json:"servers"`
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"schemas"`
+	} `json:"components"`
+}
+
+func loadOpenAPISpec(t *testing.T) openAPISpec {
+	t.Helper()
+	data, err := staticFiles.ReadFile("static/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var spec openAPISpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		t.Fatalf("invalid spec: %v", err)
+	}
+	return spec
+}
+
+var pathParam = regexp.MustCompile(`:(\w+)`)
+
+// The spec is written by hand, every route must be documented and every
+// documented operation must exist.
+func TestOpenAPIRoutes(t *testing.T) {
+	spec := loadOpenAPISpec(t)
+	if len(spec.Servers) != 1 {
+		t.Fatalf("spec has %d servers, want 1", len(spec.Servers))
+	}
+	base := spec.Servers[0].URL
+
+	var documented []string
+	for path, item := range spec.Paths {
+		prefix := base
+		if servers, ok := item["servers"]; ok {
+			var override []struct {
+				URL string `json:"url"`
+			}
+			if err := json.Unmarshal(servers, &override); err != nil || len(override) != 1 {
+				t.Fatalf("%s: invalid servers %s", path, servers)
+			}
+			prefix = strings.TrimSuffix(override[0].URL, "/")
+		}
+		for method := range item {
+			if method == "servers" || method == "parameters" {
+				continue
+			}
+			documented = append(documented, strings.ToUpper(method)+" "+prefix+path)
+		}
+	}
+
+	gin.SetMode(gin.TestMode)
+	var registered []string
+	for _, route := range (&Server{}).newRouter().Routes() {
+		switch route.Path {
+		case "/", "/docs", base + "/openapi.json":
+			continue // pages and the spec itself
+		}
+		registered = append(registered, route.Method+" "+pathParam.ReplaceAllString(route.Path, "{$1}"))
+	}
+
+	for _, op := range registered {
+		if !slices.Contains(documented, op) {
+			t.Errorf("%s is not in the spec", op)
+		}
+	}
+	for _, op := range documented {
+		if !slices.Contains(registered, op) {
+			t.Errorf("%s is in the spec but not registered", op)
+		}
+	}
+}
+
+// The schemas must list the json fields of the types they describe.
+func TestOpenAPISchemas(t *testing.T) {
+	spec := loadOpenAPISpec(t)
+	types := map[string]any{
+		"APIResponse":             APIResponse{},
+		"PlaylistItem":            PlaylistItem{},
+		"InsertVideoPayload":      mywebsocket.InsertVideoPayload{},
+		"RemoveVideoPayload":      mywebsocket.RemoveVideoPayload{},
+		"MoveVideoPayload":        mywebsocket.MoveVideoPayload{},
+		"EnqueueVideoPayload":     mywebsocket.EnqueueVideoPayload{},
+		"DequeueVideoPayload":     mywebsocket.DequeueVideoPayload{},
+		"SetPlayModePayload":      mywebsocket.SetPlayModePayload{},
+		"JumpPayload":             mywebsocket.JumpPayload{},
+		"SeekPayload":             mywebsocket.SeekPayload{},
+		"SetOutputEnabledPayload": mywebsocket.SetOutputEnabledPayload{},
+		"Request":                 mywebsocket.Request{},
+		"Date":                    mywebsocket.Date{},
+		"OutputStatus":            streamer.OutputStatus{},
+		"Progress":                streamer.Progress{},
+		"LogLine":                 streamer.LogLine{},
+		"ScheduleStatus":          streamer.ScheduleStatus{},
+		"Programme":               streamer.Programme{},
+		"EPG":                     EPGResponse{},
+		"AudioTrack":              library.AudioTrack{},
+		"MediaInfo":               library.MediaInfo{},
+	}
+	for name, v := range types {
+		t.Run(name, func(t *testing.T) {
+			schema, ok := spec.Components.Schemas[name]
+			if !ok {
+				t.Fatalf("schema %s is not in the spec", name)
+			}
+			fields := jsonFields(reflect.TypeOf(v))
+			for _, field := range fields {
+				if _, ok := schema.Properties[field]; !ok {
+					t.Errorf("field %s is not in the schema", field)
+				}
+			}
+			for property := range schema.Properties {
+				if !slices.Contains(fields, property) {
+					t.Errorf("property %s is not a field", property)
+				}
+			}
+		})
+	}
+}
+
+// jsonFields returns the names the fields of a struct are encoded as
+func jsonFields(typ reflect.Type) []string {
+	var fields []string
+	for _, field := range reflect.VisibleFields(typ) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = field.Name
+		}
+		fields = append(fields, name)
+	}
+	return fields
+}
@@ -4,6 +4,7 @@ import (
 	"embed"
 	"html/template"
 	"live-streamer/config"
+	"live-streamer/library"
 	"live-streamer/streamer"
 	mywebsocket "live-streamer/websocket"
 	"log"
@@ -25,7 +26,7 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-type InputFunc func(mywebsocket.RequestType)
+type InputFunc func(mywebsocket.Request) error
 
 type Server struct {
 	addr          string
@@ -35,10 +36,10 @@ type Server struct {
 }
 
 type Client struct {
-	id          string
-	conn        *websocket.Conn
-	mu          sync.Mutex
-	hasSentSize int
+	id     string
+	conn   *websocket.Conn
+	mu     sync.Mutex
+	cursor uint64 // next output line to send
 }
 
 var GlobalServer *Server
@@ -53,6 +54,19 @@ func NewServer(addr string, dealInputFunc InputFunc) {
 
 func (s *Server) Run() {
 	gin.SetMode(gin.ReleaseMode)
+	router := s.newRouter()
+
+	go s.broadcastStatus()
+
+	go func() {
+		if err := router.Run(s.addr); err != nil {
+			log.Fatalf("Error starting server: %v", err)
+		}
+	}()
+}
+
+// newRouter registers the pages, the websocket and the api
+func (s *Server) newRouter() *gin.Engine {
 	router := gin.New()
 	tpl, err := template.ParseFS(staticFiles, "static/*")
 	if err != nil {
@@ -61,17 +75,76 @@ func (s *Server) Run() {
 	router.SetHTMLTemplate(tpl)
 
 	router.GET("/ws", AuthMiddleware(), s.handleWebSocket)
+	// the spec and its docs page are public so the docs can be read without a token
+	router.GET("/api/v1/openapi.json", func(c *gin.Context) {
+		c.FileFromFS("static/openapi.json", http.FS(staticFiles))
+	})
+	router.GET("/docs", func(c *gin.Context) {
+		c.HTML(200, "docs.html", nil)
+	})
+	registerAPI(router.Group("/api/v1", AuthMiddleware()))
 	router.GET(
 		"/", func(c *gin.Context) {
 			c.HTML(200, "index.html", nil)
 		},
 	)
+	return router
+}
 
-	go func() {
-		if err := router.Run(s.addr); err != nil {
-			log.Fatalf("Error starting server: %v", err)
+// CurrentStatus returns the status of the streamer without output lines
+func CurrentStatus() mywebsocket.Date {
+	videoList := streamer.GlobalStreamer.GetVideoListPath()
+	return mywebsocket.Date{
+		Timestamp:        time.Now().UnixMilli(),
+		CurrentVideoPath: streamer.GlobalStreamer.GetCurrentVideoPath(),
+		CurrentIndex:     streamer.GlobalStreamer.GetCurrentIndex(),
+		Paused:           streamer.GlobalStreamer.IsPaused(),
+		PlayMode:         streamer.GlobalStreamer.GetPlayMode(),
+		Finished:         streamer.GlobalStreamer.IsFinished(),
+		Position:         streamer.GlobalStreamer.GetPosition().Seconds(),
+		Progress:         streamer.GlobalStreamer.GetProgress(),
+		FrameRate:        config.Get().Play.FrameRate,
+		VideoList:        videoList,
+		Media:            mediaInfo(videoList),
+		Queue:            streamer.GlobalStreamer.GetQueue(),
+		Outputs:          streamer.GlobalStreamer.GetOutputs(),
+		FailedVideos:     streamer.GlobalStreamer.GetFailedVideos(),
+		BadMedia:         library.GlobalLibrary.BadMedia(),
+	}
+}
+
+// mediaInfo returns the media info of the probed videos in paths
+func mediaInfo(paths []string) map[string]library.MediaInfo {
+	media := make(map[string]library.MediaInfo, len(paths))
+	for _, path := range paths {
+		if info, ok := library.GlobalLibrary.Lookup(path); ok {
+			media[path] = info
 		}
-	}()
+	}
+	return media
+}
+
+// broadcastStatus sends the status to every client once a second, each
+// client only receives the output lines it hasn't got yet.
+func (s *Server) broadcastStatus() {
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+	for range ticker.C {
+		status := CurrentStatus()
+		s.mu.Lock()
+		for _, client := range s.clients {
+			obj := status
+			obj.Timestamp = time.Now().UnixMilli()
+			var next uint64
+			obj.Logs, next = streamer.GlobalStreamer.GetOutputSince(client.cursor)
+			if err := client.conn.WriteJSON(obj); err != nil {
+				log.Printf("websocket writing message error: %v", err)
+				continue
+			}
+			client.cursor = next
+		}
+		s.mu.Unlock()
+	}
 }
 
 func (s *Server) handleWebSocket(c *gin.Context) {
@@ -89,7 +162,7 @@ func (s *Server) handleWebSocket(c *gin.Context) {
 		log.Printf("generating uuid error: %v", err)
 		return
 	}
-	client := &Client{id: id.String(), conn: ws, hasSentSize: 0}
+	client := &Client{id: id.String(), conn: ws, cursor: 0}
 	s.mu.Lock()
 	s.clients[client.id] = client
 	s.mu.Unlock()
@@ -106,18 +179,6 @@ func (s *Server) handleWebSocket(c *gin.Context) {
 		}
 	}()
 
-	go func() {
-		ticker := time.NewTicker(1 * time.Second)
-		for range ticker.C {
-			s.Broadcast(mywebsocket.Date{
-				Timestamp:        time.Now().UnixMilli(),
-				CurrentVideoPath: streamer.GlobalStreamer.GetCurrentVideoPath(),
-				VideoList:        streamer.GlobalStreamer.GetVideoListPath(),
-				Output:           streamer.GlobalStreamer.GetOutput(),
-			})
-		}
-	}()
-
 	for {
 		// recive message
 		client.mu.Lock()
@@ -130,14 +191,19 @@ func (s *Server) handleWebSocket(c *gin.Context) {
 			}
 			break
 		}
-		s.dealInputFunc(msg.Type)
+		if err := s.dealInputFunc(msg); err != nil {
+			log.Printf("websocket request %s error: %v", msg.Type, err)
+		}
 	}
 }
 
+// AuthMiddleware accepts the token as the token query parameter or as an
+// "Authorization: Bearer <token>" header.
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if config.GlobalConfig.Server.Token == "" ||
-			c.Query("token") == config.GlobalConfig.Server.Token {
+		if config.Get().Server.Token == "" ||
+			c.Query("token") == config.Get().Server.Token ||
+			c.GetHeader("Authorization") == "Bearer "+config.Get().Server.Token {
 			c.Next()
 		} else {
 			c.AbortWithStatus(http.StatusUnauthorized)
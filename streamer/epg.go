@@ -0,0 +1,223 @@
+package streamer
+
+import (
+	"live-streamer/config"
+	"live-streamer/constant"
+	"live-streamer/library"
+	"live-streamer/utils"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Programme is an entry of the projected program guide
+type Programme struct {
+	Title    string `json:"title"`
+	Path     string `json:"path"`
+	Schedule string `json:"schedule,omitempty"` // program of the schedule the video belongs to
+	Start    int64  `json:"start"`              // unix milliseconds
+	Stop     int64  `json:"stop"`               // unix milliseconds
+}
+
+// more entries than this aren't worth projecting
+const maxGuideEntries = 1000
+
+// videoDuration returns the cached duration of the video at path, 0 if it
+// hasn't been probed yet or can't be. The guide must not wait for ffprobe,
+// the library scan fills in the rest.
+func videoDuration(path string) time.Duration {
+	info, ok := library.GlobalLibrary.Lookup(path)
+	if !ok || info.Error != "" {
+		return 0
+	}
+	return time.Duration(info.Duration * float64(time.Second))
+}
+
+// playedRange returns where ffmpeg starts and stops playing item
+func playedRange(item config.InputItem) (time.Duration, time.Duration) {
+	var start time.Duration
+	if item.Start != "" {
+		start, _ = utils.ParseDuration(item.Start)
+	}
+	end := videoDuration(item.Path)
+	if item.End != "" {
+		if itemEnd, err := utils.ParseDuration(item.End); err == nil && (end == 0 || itemEnd < end) {
+			end = itemEnd
+		}
+	}
+	return start, end
+}
+
+type guideItem struct {
+	item     config.InputItem
+	schedule string
+}
+
+type guideProgram struct {
+	schedule config.ScheduleConfig
+	start    time.Time
+}
+
+// guideLoop yields the videos the playlist is going to play. Only the next
+// video is known in shuffle and random mode.
+type guideLoop struct {
+	videoList []config.InputItem
+	failed    map[string]string
+	mode      string
+	next      int
+	ok        bool
+}
+
+func (l *guideLoop) pop() (config.InputItem, bool) {
+	videoLen := len(l.videoList)
+	if !l.ok || videoLen == 0 {
+		return config.InputItem{}, false
+	}
+	index := -1
+	for i := 0; i < videoLen; i++ {
+		candidate := (l.next + i) % videoLen
+		path := l.videoList[candidate].Path
+		_, failed := l.failed[path]
+		if _, bad := library.GlobalLibrary.Bad(path); !bad && !failed {
+			index = candidate
+			break
+		}
+	}
+	if index < 0 {
+		l.ok = false
+		return config.InputItem{}, false
+	}
+	l.next, l.ok = l.after(index)
+	return l.videoList[index], true
+}
+
+// after returns the index played after index like advanceLocked does
+func (l *guideLoop) after(index int) (int, bool) {
+	switch l.mode {
+	case constant.PlayModeRepeatOne:
+		return index, true
+	case constant.PlayModeOnce:
+		return index + 1, index+1 < len(l.videoList)
+	case constant.PlayModeShuffle, constant.PlayModeRandom:
+		return 0, false
+	}
+	return (index + 1) % len(l.videoList), true
+}
+
+// GetGuide projects what is going to be played during horizon from the
+// playlist order, the queue, the schedule and the position of the current
+// video.
+func (s *Streamer) GetGuide(horizon time.Duration) []Programme {
+	return s.guideAt(time.Now(), horizon)
+}
+
+// guideAt is GetGuide at now
+func (s *Streamer) guideAt(now time.Time, horizon time.Duration) []Programme {
+	until := now.Add(horizon)
+
+	s.videoMu.RLock()
+	s.playStateMu.RLock()
+	loop := &guideLoop{
+		videoList: slices.Clone(s.videoList),
+		failed:    make(map[string]string, len(s.playState.failedVideos)),
+		mode:      s.playState.mode,
+		next:      s.playState.currentVideoIndex,
+		ok:        !s.playState.finished,
+	}
+	for path, reason := range s.playState.failedVideos {
+		loop.failed[path] = reason
+	}
+	var pending []guideItem
+	for _, path := range s.playState.queue {
+		pending = append(pending, guideItem{item: config.InputItem{Path: path}})
+	}
+	var current *config.InputItem
+	var position time.Duration
+	if s.playState.playingPath != "" {
+		position = s.positionLocked()
+		if s.playState.queuedPath != "" {
+			current = &config.InputItem{Path: s.playState.queuedPath}
+		} else if index := s.playState.currentVideoIndex; index < len(s.videoList) {
+			current = &s.videoList[index]
+			loop.next, loop.ok = loop.after(index)
+		}
+	}
+	s.playStateMu.RUnlock()
+	s.videoMu.RUnlock()
+
+	// programs already started are in the queue
+	var programs []guideProgram
+	for _, schedule := range config.Get().Schedule {
+		for t := nextScheduleTime(schedule, now); !t.IsZero() && !t.After(until); t = nextScheduleTime(schedule, t) {
+			programs = append(programs, guideProgram{schedule: schedule, start: t})
+		}
+	}
+	slices.SortStableFunc(programs, func(a, b guideProgram) int {
+		return a.start.Compare(b.start)
+	})
+
+	guide := []Programme{}
+	// add appends the entry of item played from start to stop and returns
+	// when it really stops, a program starting hard cuts it.
+	add := func(item guideItem, start, stop time.Time) time.Time {
+		if len(programs) > 0 && programs[0].schedule.HardStart && programs[0].start.Before(stop) {
+			stop = programs[0].start
+		}
+		guide = append(guide, Programme{
+			Title:    strings.TrimSuffix(filepath.Base(item.item.Path), filepath.Ext(item.item.Path)),
+			Path:     item.item.Path,
+			Schedule: item.schedule,
+			Start:    start.UnixMilli(),
+			Stop:     stop.UnixMilli(),
+		})
+		return stop
+	}
+
+	t := now
+	if current != nil {
+		start, end := playedRange(*current)
+		if end > position {
+			t = add(guideItem{item: *current}, now.Add(start-position), now.Add(end-position))
+		}
+	}
+	skipped := 0
+	for t.Before(until) && len(guide) < maxGuideEntries {
+		for len(programs) > 0 && !programs[0].start.After(t) {
+			videos, _ := programs[0].schedule.Videos()
+			items := make([]guideItem, 0, len(videos))
+			for _, video := range videos {
+				items = append(items, guideItem{item: video, schedule: programs[0].schedule.Name})
+			}
+			pending = append(items, pending...)
+			programs = programs[1:]
+		}
+
+		var item guideItem
+		if len(pending) > 0 {
+			item, pending = pending[0], pending[1:]
+		} else if video, ok := loop.pop(); ok {
+			item = guideItem{item: video}
+		} else if len(programs) > 0 {
+			// nothing to play until the next program
+			t = programs[0].start
+			continue
+		} else {
+			break
+		}
+
+		start, end := playedRange(item.item)
+		if end <= start {
+			// a video that can't be probed can't be placed, give up when
+			// none of them can
+			skipped++
+			if skipped > len(loop.videoList)+len(pending) {
+				break
+			}
+			continue
+		}
+		skipped = 0
+		t = add(item, t, t.Add(end-start))
+	}
+	return guide
+}
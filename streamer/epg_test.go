@@ -0,0 +1,198 @@
+package streamer
+
+import (
+	"encoding/json"
+	"live-streamer/config"
+	"live-streamer/constant"
+	"live-streamer/library"
+	"live-streamer/utils"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// useMediaDurations makes the library know the duration of the videos in
+// seconds, other videos haven't been probed.
+func useMediaDurations(t *testing.T, durations map[string]float64) {
+	t.Helper()
+	entries := make(map[string]any, len(durations))
+	for path, duration := range durations {
+		entries[path] = map[string]any{"checked": true, "info": map[string]any{"duration": duration}}
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cacheFile := filepath.Join(t.TempDir(), "media_cache.json")
+	if err := os.WriteFile(cacheFile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := library.GlobalLibrary
+	library.NewLibrary(cacheFile)
+	t.Cleanup(func() { library.GlobalLibrary = old })
+}
+
+func TestGuide(t *testing.T) {
+	news := filepath.Join(t.TempDir(), "news.mp4")
+	if err := os.WriteFile(news, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	useMediaDurations(t, map[string]float64{"a.mp4": 60, "b.mp4": 120, "q.mp4": 30, news: 30})
+	now := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	at := func(hour, min int, hardStart bool) config.ScheduleConfig {
+		return config.ScheduleConfig{Name: "news", Input: []string{news}, Hour: hour, Minute: min, HardStart: hardStart}
+	}
+
+	type entry struct {
+		path        string
+		start, stop time.Duration // since now
+		schedule    string
+	}
+	tests := []struct {
+		name     string
+		videos   []config.InputItem
+		mode     string
+		current  int
+		position time.Duration // playing if not negative
+		queue    []string
+		schedule []config.ScheduleConfig
+		horizon  time.Duration
+		want     []entry
+	}{
+		{
+			name:     "current video offset",
+			videos:   []config.InputItem{{Path: "a.mp4"}, {Path: "b.mp4"}},
+			position: 20 * time.Second,
+			horizon:  4 * time.Minute,
+			want: []entry{
+				{"a.mp4", -20 * time.Second, 40 * time.Second, ""},
+				{"b.mp4", 40 * time.Second, 160 * time.Second, ""},
+				{"a.mp4", 160 * time.Second, 220 * time.Second, ""},
+				{"b.mp4", 220 * time.Second, 340 * time.Second, ""},
+			},
+		},
+		{
+			name:     "current video with start and end",
+			videos:   []config.InputItem{{Path: "a.mp4", Start: "10", End: "50"}, {Path: "b.mp4"}},
+			current:  0,
+			position: 30 * time.Second,
+			horizon:  time.Minute,
+			want: []entry{
+				{"a.mp4", -20 * time.Second, 20 * time.Second, ""},
+				{"b.mp4", 20 * time.Second, 140 * time.Second, ""},
+			},
+		},
+		{
+			name:     "between videos",
+			videos:   []config.InputItem{{Path: "a.mp4"}, {Path: "b.mp4"}},
+			current:  1,
+			position: -1,
+			horizon:  time.Minute,
+			want:     []entry{{"b.mp4", 0, 120 * time.Second, ""}},
+		},
+		{
+			name:     "queue first",
+			videos:   []config.InputItem{{Path: "a.mp4"}, {Path: "b.mp4"}},
+			position: 0,
+			queue:    []string{"q.mp4"},
+			horizon:  2 * time.Minute,
+			want: []entry{
+				{"a.mp4", 0, time.Minute, ""},
+				{"q.mp4", time.Minute, 90 * time.Second, ""},
+				{"b.mp4", 90 * time.Second, 210 * time.Second, ""},
+			},
+		},
+		{
+			name:     "hard start cuts the playing video",
+			videos:   []config.InputItem{{Path: "b.mp4"}, {Path: "a.mp4"}},
+			position: 0,
+			schedule: []config.ScheduleConfig{at(10, 1, true)},
+			horizon:  2 * time.Minute,
+			want: []entry{
+				{"b.mp4", 0, time.Minute, ""},
+				{news, time.Minute, 90 * time.Second, "news"},
+				{"a.mp4", 90 * time.Second, 150 * time.Second, ""},
+			},
+		},
+		{
+			name:     "soft start waits for the video to end",
+			videos:   []config.InputItem{{Path: "b.mp4"}, {Path: "a.mp4"}},
+			position: 0,
+			queue:    []string{"q.mp4"},
+			schedule: []config.ScheduleConfig{at(10, 1, false)},
+			horizon:  3 * time.Minute,
+			want: []entry{
+				{"b.mp4", 0, 2 * time.Minute, ""},
+				{news, 2 * time.Minute, 150 * time.Second, "news"},
+				{"q.mp4", 150 * time.Second, 3 * time.Minute, ""},
+			},
+		},
+		{
+			name:     "once mode waits for the program",
+			videos:   []config.InputItem{{Path: "a.mp4"}},
+			mode:     constant.PlayModeOnce,
+			position: 0,
+			schedule: []config.ScheduleConfig{at(10, 5, false)},
+			horizon:  10 * time.Minute,
+			want: []entry{
+				{"a.mp4", 0, time.Minute, ""},
+				{news, 5 * time.Minute, 330 * time.Second, "news"},
+			},
+		},
+		{
+			name:     "videos never probed are skipped",
+			videos:   []config.InputItem{{Path: "x.mp4"}, {Path: "a.mp4"}, {Path: "y.mp4"}},
+			mode:     constant.PlayModeOnce,
+			position: -1,
+			horizon:  time.Hour,
+			want:     []entry{{"a.mp4", 0, time.Minute, ""}},
+		},
+		{
+			name:     "no video probed",
+			videos:   []config.InputItem{{Path: "x.mp4"}, {Path: "y.mp4"}},
+			position: -1,
+			queue:    []string{"z.mp4"},
+			horizon:  time.Hour,
+			want:     nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := config.Get()
+			config.Set(&config.Config{Schedule: tt.schedule})
+			t.Cleanup(func() { config.Set(old) })
+
+			s := newTestStreamer()
+			s.videoList = tt.videos
+			if tt.mode != "" {
+				s.playState.mode = tt.mode
+			}
+			s.playState.currentVideoIndex = tt.current
+			s.playState.queue = tt.queue
+			if tt.position >= 0 {
+				item := tt.videos[tt.current]
+				s.playState.playingPath = item.Path
+				s.playState.startedAt = now
+				if item.Start != "" {
+					s.playState.startOffset, _ = utils.ParseDuration(item.Start)
+				}
+				s.setProgress(Progress{OutTime: (tt.position - s.playState.startOffset).Seconds(), UpdatedAt: 1})
+			}
+
+			guide := s.guideAt(now, tt.horizon)
+			if len(guide) != len(tt.want) {
+				t.Fatalf("got %d entries, want %d: %+v", len(guide), len(tt.want), guide)
+			}
+			for i, want := range tt.want {
+				got := guide[i]
+				if got.Path != want.path || got.Schedule != want.schedule ||
+					got.Start != now.Add(want.start).UnixMilli() || got.Stop != now.Add(want.stop).UnixMilli() {
+					t.Errorf("entry %d = %s %v-%v %q, want %s %v-%v %q", i,
+						got.Path, time.UnixMilli(got.Start).Sub(now), time.UnixMilli(got.Stop).Sub(now), got.Schedule,
+						want.path, want.start, want.stop, want.schedule)
+				}
+			}
+		})
+	}
+}
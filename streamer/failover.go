@@ -0,0 +1,170 @@
+package streamer
+
+import (
+	"fmt"
+	"live-streamer/config"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// outputHealth tracks the publish failures of an output to decide when to
+// switch between its primary and backup endpoint.
+type outputHealth struct {
+	usingBackup  bool
+	failures     int // consecutive failures of the active endpoint
+	lastFailure  time.Time
+	reconnecting bool // a restart to reopen the output is scheduled
+}
+
+const (
+	primaryProbeInterval = 30 * time.Second
+	primaryProbeTimeout  = 5 * time.Second
+)
+
+// tee reports failed destinations like "Slave muxer #1 failed: ..."
+var teeSlaveFailedRegexp = regexp.MustCompile(`Slave muxer #(\d+) failed`)
+
+func (s *Streamer) outputHealthLocked(name string) *outputHealth {
+	health, ok := s.outputHealth[name]
+	if !ok {
+		health = &outputHealth{}
+		s.outputHealth[name] = health
+	}
+	return health
+}
+
+// outputFailed records a publish failure of the named output, after
+// failover_threshold failures in a row it switches to the backup endpoint.
+// It returns whether the output has switched.
+func (s *Streamer) outputFailed(name string) bool {
+	s.destMu.Lock()
+	health := s.outputHealthLocked(name)
+	health.failures++
+	health.lastFailure = time.Now()
+	switched := false
+	for _, output := range config.Get().Outputs {
+		if output.Name == name && output.BackupRTMPServer != "" && !health.usingBackup &&
+			health.failures >= config.Get().Retry.FailoverThreshold {
+			health.usingBackup = true
+			health.failures = 0
+			switched = true
+			s.writeOutput(fmt.Sprintf("output %s failed %d times, switch to backup %s\n",
+				name, config.Get().Retry.FailoverThreshold, output.BackupRTMPServer))
+		}
+	}
+	s.destMu.Unlock()
+	return switched
+}
+
+// outputsSucceeded clears the failures of outputs that haven't failed since
+// since, called after they published for a while.
+func (s *Streamer) outputsSucceeded(outputs []config.OutputConfig, since time.Time) {
+	s.destMu.Lock()
+	defer s.destMu.Unlock()
+	for _, output := range outputs {
+		health := s.outputHealthLocked(output.Name)
+		if health.lastFailure.Before(since) {
+			health.failures = 0
+		}
+	}
+}
+
+// checkTeeFailure looks for a failed tee destination in a line of stderr,
+// outputs must be the destinations in the order they were passed to tee.
+func (s *Streamer) checkTeeFailure(line string, outputs []config.OutputConfig) {
+	if len(outputs) < 2 {
+		return
+	}
+	match := teeSlaveFailedRegexp.FindStringSubmatch(line)
+	if match == nil {
+		return
+	}
+	index, err := strconv.Atoi(match[1])
+	if err != nil || index >= len(outputs) {
+		return
+	}
+	// tee doesn't reopen a failed destination, restart to try again, at once
+	// only when switching to the backup
+	name := outputs[index].Name
+	if s.outputFailed(name) {
+		go s.restartOutput()
+		return
+	}
+	s.scheduleReconnect(name)
+}
+
+// scheduleReconnect restarts the output after a backoff to reopen a failed
+// destination, the other destinations keep publishing until then.
+func (s *Streamer) scheduleReconnect(name string) {
+	s.destMu.Lock()
+	defer s.destMu.Unlock()
+	health := s.outputHealthLocked(name)
+	if health.reconnecting {
+		return
+	}
+	health.reconnecting = true
+	delay := backoff(health.failures)
+	s.writeOutput(fmt.Sprintf("output %s failed, reconnect in %v\n", name, delay))
+	time.AfterFunc(delay, func() {
+		s.destMu.Lock()
+		health.reconnecting = false
+		s.destMu.Unlock()
+		s.restartOutput()
+	})
+}
+
+// watchPrimaries switches outputs back to their primary endpoint once it
+// accepts connections again.
+func (s *Streamer) watchPrimaries() {
+	ticker := time.NewTicker(primaryProbeInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		var recovered []string
+		for _, output := range config.Get().Outputs {
+			s.destMu.RLock()
+			health, ok := s.outputHealth[output.Name]
+			usingBackup := ok && health.usingBackup
+			s.destMu.RUnlock()
+			if usingBackup && probeRTMP(output.RTMPServer) {
+				recovered = append(recovered, output.Name)
+			}
+		}
+		if len(recovered) == 0 {
+			continue
+		}
+		s.destMu.Lock()
+		for _, name := range recovered {
+			health := s.outputHealthLocked(name)
+			health.usingBackup = false
+			health.failures = 0
+			s.writeOutput(fmt.Sprintf("output %s primary recovered, switch back\n", name))
+		}
+		s.destMu.Unlock()
+		s.restartOutput()
+	}
+}
+
+// probeRTMP checks whether the rtmp server accepts tcp connections
+func probeRTMP(server string) bool {
+	u, err := url.Parse(server)
+	if err != nil {
+		return false
+	}
+	host := u.Host
+	if u.Port() == "" {
+		port := "1935"
+		if u.Scheme == "rtmps" {
+			port = "443"
+		}
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+	conn, err := net.DialTimeout("tcp", host, primaryProbeTimeout)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
@@ -0,0 +1,194 @@
+package streamer
+
+import (
+	"fmt"
+	"live-streamer/config"
+	"live-streamer/constant"
+	"live-streamer/library"
+	"strings"
+	"time"
+)
+
+type failureKind int
+
+const (
+	failureNone   failureKind = iota
+	failureInput              // the video can't be read, skip it
+	failureOutput             // the rtmp server can't be reached, retry later
+)
+
+func (k failureKind) String() string {
+	switch k {
+	case failureInput:
+		return "input error"
+	case failureOutput:
+		return "output error"
+	}
+	return "no error"
+}
+
+// a run longer than this is not counted as a consecutive failure
+const stableRunTime = 30 * time.Second
+
+// stderr fragments of failures writing to the rtmp server
+var outputErrorPatterns = []string{
+	"connection refused",
+	"connection reset",
+	"connection timed out",
+	"broken pipe",
+	"network is unreachable",
+	"no route to host",
+	"name or service not known",
+	"temporary failure in name resolution",
+	"cannot open connection",
+	"[rtmp @",
+	"[tcp @",
+	"[tls @",
+	"handshake",
+	"server error",
+	"error opening output",
+	"could not write header",
+	"error writing trailer",
+	"av_interleaved_write_frame",
+	"error muxing a packet",
+	"slave muxer",
+}
+
+// stderr fragments of failures reading the video
+var inputErrorPatterns = []string{
+	"no such file or directory",
+	"invalid data found when processing input",
+	"moov atom not found",
+	"error opening input",
+	"could not find codec parameters",
+	"stream map '0:v:0' matches no streams",
+	"matches no streams",
+	"permission denied",
+	"decoding error",
+	"error while decoding",
+}
+
+// classifyFailure decides from the last lines of stderr whether a failed
+// ffmpeg couldn't read its input or couldn't push its output. Output
+// errors are checked first, an unreachable server must never get videos
+// marked as broken. Unknown errors are blamed on the input so a video
+// that always fails can't hold up the playlist forever.
+func classifyFailure(stderr []string) failureKind {
+	text := strings.ToLower(strings.Join(stderr, "\n"))
+	for _, pattern := range outputErrorPatterns {
+		if strings.Contains(text, pattern) {
+			return failureOutput
+		}
+	}
+	for _, pattern := range inputErrorPatterns {
+		if strings.Contains(text, pattern) {
+			return failureInput
+		}
+	}
+	return failureInput
+}
+
+// backoff returns how long to wait before the n-th consecutive retry
+func backoff(n int) time.Duration {
+	d := time.Duration(config.Get().Retry.InitialBackoff) * time.Second
+	maxBackoff := time.Duration(config.Get().Retry.MaxBackoff) * time.Second
+	for i := 1; i < n && d < maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxBackoff)
+}
+
+// retryOutputLocked schedules the video at position to be played again
+// after a backoff, must be called with playStateMu held.
+func (s *Streamer) retryOutputLocked(resume *resumePoint, ranFor time.Duration) time.Duration {
+	if ranFor > stableRunTime {
+		s.playState.outputFailures = 0
+	}
+	s.playState.outputFailures++
+	wait := backoff(s.playState.outputFailures)
+	s.playState.retryAt = time.Now().Add(wait)
+	s.playState.resume = resume
+	return wait
+}
+
+// recordInputFailureLocked counts a failure of videoPath and marks it failed
+// when it failed too many times in a row, must be called with playStateMu
+// held. It returns whether the video got marked.
+func (s *Streamer) recordInputFailureLocked(videoPath, reason string) bool {
+	s.playState.inputFailures[videoPath]++
+	if s.playState.inputFailures[videoPath] < config.Get().Retry.MaxInputFailures {
+		return false
+	}
+	delete(s.playState.inputFailures, videoPath)
+	s.playState.failedVideos[videoPath] = config.Redact(reason)
+	return true
+}
+
+// unplayableLocked returns whether the video at videoPath is marked failed or
+// is bad media, must be called with playStateMu held.
+func (s *Streamer) unplayableLocked(videoPath string) bool {
+	if _, failed := s.playState.failedVideos[videoPath]; failed {
+		return true
+	}
+	_, bad := library.GlobalLibrary.Bad(videoPath)
+	return bad
+}
+
+// playableIndexLocked returns the first video from the current index on that
+// isn't failed or bad media, wrapping around unless in once mode. Must be
+// called with videoMu and playStateMu held.
+func (s *Streamer) playableIndexLocked() (int, bool) {
+	videoLen := len(s.videoList)
+	if videoLen == 0 {
+		return 0, false
+	}
+	index := s.playState.currentVideoIndex
+	if index < 0 || index >= videoLen {
+		index = 0
+	}
+	// once mode doesn't start over when the videos left can't be played
+	last := index + videoLen
+	if s.playState.mode == constant.PlayModeOnce {
+		last = videoLen
+	}
+	for i := index; i < last; i++ {
+		candidate := i % videoLen
+		if !s.unplayableLocked(s.videoList[candidate].Path) {
+			return candidate, true
+		}
+	}
+	return 0, false
+}
+
+// retryWait returns how long Stream has to wait before the next attempt
+func (s *Streamer) retryWait() time.Duration {
+	s.playStateMu.RLock()
+	defer s.playStateMu.RUnlock()
+	return time.Until(s.playState.retryAt)
+}
+
+// GetFailedVideos returns the videos skipped because they repeatedly failed
+// to play, with the reason.
+func (s *Streamer) GetFailedVideos() map[string]string {
+	s.playStateMu.RLock()
+	defer s.playStateMu.RUnlock()
+	failed := make(map[string]string, len(s.playState.failedVideos))
+	for path, reason := range s.playState.failedVideos {
+		failed[path] = reason
+	}
+	return failed
+}
+
+// lastLine returns the last non empty line, used as the failure reason
+func lastLine(lines []string) string {
+	for i := len(lines) - 1; i >= 0; i-- {
+		if line := strings.TrimSpace(lines[i]); line != "" {
+			return line
+		}
+	}
+	return "unknown error"
+}
+
+func (s *Streamer) writeFailure(videoPath string, kind failureKind, err error) {
+	s.writeOutput(fmt.Sprintf("ffmpeg failed on %s (%s): %v\n", videoPath, kind, err))
+}
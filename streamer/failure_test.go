@@ -0,0 +1,28 @@
+package streamer
+
+import "testing"
+
+func TestClassifyFailure(t *testing.T) {
+	tests := []struct {
+		name   string
+		stderr []string
+		want   failureKind
+	}{
+		{"connection refused", []string{"[tcp @ 0x1] Connection to tcp://127.0.0.1:1935 failed: Connection refused"}, failureOutput},
+		{"rtmp handshake", []string{"[rtmp @ 0x1] Handshake failed"}, failureOutput},
+		{"broken pipe", []string{"av_interleaved_write_frame(): Broken pipe", "Error writing trailer of rtmp://a/live/key: Broken pipe"}, failureOutput},
+		{"dns", []string{"Failed to resolve hostname live.example.com: Temporary failure in name resolution"}, failureOutput},
+		{"tee slave", []string{"[tee @ 0x1] Slave muxer #1 failed: I/O error, continuing with 1/2 slaves."}, failureOutput},
+		{"missing file", []string{"videos/a.mp4: No such file or directory"}, failureInput},
+		{"broken file", []string{"[mov,mp4,m4a,3gp,3g2,mj2 @ 0x1] moov atom not found", "videos/a.mp4: Invalid data found when processing input"}, failureInput},
+		{"no video stream", []string{"Stream map '0:v:0' matches no streams."}, failureInput},
+		{"output wins over input", []string{"Error while decoding stream #0:0", "[rtmp @ 0x1] Server error: Already publishing"}, failureOutput},
+		{"unknown", []string{"Conversion failed!"}, failureInput},
+		{"empty", nil, failureInput},
+	}
+	for _, tt := range tests {
+		if got := classifyFailure(tt.stderr); got != tt.want {
+			t.Errorf("%s: classifyFailure(%q) = %v, want %v", tt.name, tt.stderr, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package streamer
+
+import (
+	"strings"
+	"sync"
+)
+
+type LogLine struct {
+	Seq  uint64 `json:"seq"`
+	Text string `json:"text"`
+}
+
+// logBuffer keeps the latest lines of the output in a ring, every line gets
+// an increasing sequence number so readers can fetch only what they haven't
+// seen yet.
+type logBuffer struct {
+	mu      sync.RWMutex
+	lines   []LogLine
+	start   int // position of the oldest line in lines
+	count   int
+	nextSeq uint64
+	partial string // last line not terminated by a newline yet
+}
+
+func newLogBuffer(capacity int) *logBuffer {
+	return &logBuffer{lines: make([]LogLine, capacity)}
+}
+
+func (b *logBuffer) Write(str string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	str = b.partial + str
+	for {
+		i := strings.IndexByte(str, '\n')
+		if i < 0 {
+			break
+		}
+		b.append(strings.TrimSuffix(str[:i], "\r"))
+		str = str[i+1:]
+	}
+	b.partial = str
+}
+
+func (b *logBuffer) append(text string) {
+	line := LogLine{Seq: b.nextSeq, Text: text}
+	b.nextSeq++
+	if b.count < len(b.lines) {
+		b.lines[(b.start+b.count)%len(b.lines)] = line
+		b.count++
+		return
+	}
+	b.lines[b.start] = line
+	b.start = (b.start + 1) % len(b.lines)
+}
+
+// Since returns the lines numbered cursor or later that are still kept, and
+// the cursor to use for the next call.
+func (b *logBuffer) Since(cursor uint64) ([]LogLine, uint64) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	oldest := b.nextSeq - uint64(b.count)
+	if cursor < oldest {
+		cursor = oldest
+	}
+	if cursor >= b.nextSeq {
+		return nil, b.nextSeq
+	}
+	lines := make([]LogLine, 0, b.nextSeq-cursor)
+	for i := int(cursor - oldest); i < b.count; i++ {
+		lines = append(lines, b.lines[(b.start+i)%len(b.lines)])
+	}
+	return lines, b.nextSeq
+}
@@ -0,0 +1,70 @@
+package streamer
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestLogBufferSince(t *testing.T) {
+	tests := []struct {
+		name       string
+		capacity   int
+		lines      int // written as "0\n1\n..."
+		cursor     uint64
+		want       []string
+		wantCursor uint64
+	}{
+		{"empty", 3, 0, 0, nil, 0},
+		{"all lines", 3, 2, 0, []string{"0", "1"}, 2},
+		{"new lines only", 3, 3, 1, []string{"1", "2"}, 3},
+		{"up to date", 3, 3, 3, nil, 3},
+		{"cursor ahead", 3, 3, 10, nil, 3},
+		{"wrapped", 3, 5, 0, []string{"2", "3", "4"}, 5},
+		{"wrapped, cursor dropped", 3, 7, 2, []string{"4", "5", "6"}, 7},
+		{"wrapped, cursor kept", 3, 7, 5, []string{"5", "6"}, 7},
+		{"wrapped many times", 3, 31, 29, []string{"29", "30"}, 31},
+		{"capacity one", 1, 4, 0, []string{"3"}, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newLogBuffer(tt.capacity)
+			for i := range tt.lines {
+				b.Write(fmt.Sprintf("%d\n", i))
+			}
+			lines, cursor := b.Since(tt.cursor)
+			var got []string
+			for _, line := range lines {
+				if line.Text != fmt.Sprint(line.Seq) {
+					t.Errorf("line %d is %q", line.Seq, line.Text)
+				}
+				got = append(got, line.Text)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Since(%d) = %v, want %v", tt.cursor, got, tt.want)
+			}
+			if cursor != tt.wantCursor {
+				t.Errorf("cursor = %d, want %d", cursor, tt.wantCursor)
+			}
+		})
+	}
+}
+
+func TestLogBufferWrite(t *testing.T) {
+	b := newLogBuffer(10)
+	b.Write("first")
+	b.Write(" line\r\nsecond\n\nthird")
+	lines, _ := b.Since(0)
+	var got []string
+	for _, line := range lines {
+		got = append(got, line.Text)
+	}
+	// the unterminated line is kept until its newline arrives
+	if want := []string{"first line", "second", ""}; !slices.Equal(got, want) {
+		t.Errorf("lines = %q, want %q", got, want)
+	}
+	b.Write("\n")
+	if lines, _ := b.Since(3); len(lines) != 1 || lines[0].Text != "third" {
+		t.Errorf("Since(3) = %v, want third", lines)
+	}
+}
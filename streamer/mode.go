@@ -0,0 +1,126 @@
+package streamer
+
+import (
+	"fmt"
+	"live-streamer/constant"
+	"math/rand/v2"
+	"slices"
+	"strings"
+)
+
+// advanceReason tells advanceLocked why the index moves on
+type advanceReason int
+
+const (
+	advanceEnded  advanceReason = iota // the video played to the end
+	advanceFailed                      // the video couldn't be read
+	advanceNext                        // the next video was asked for
+)
+
+// advanceLocked moves the index to the video to play next according to the
+// play mode, must be called with videoMu and playStateMu held.
+func (s *Streamer) advanceLocked(reason advanceReason) {
+	videoLen := len(s.videoList)
+	if videoLen == 0 {
+		s.playState.currentVideoIndex = 0
+		return
+	}
+	switch s.playState.mode {
+	case constant.PlayModeRepeatOne:
+		if reason == advanceEnded {
+			return
+		}
+	case constant.PlayModeShuffle:
+		s.shuffleLocked()
+		return
+	case constant.PlayModeRandom:
+		if index, ok := s.randomIndexLocked(nil); ok {
+			s.playState.currentVideoIndex = index
+		}
+		return
+	case constant.PlayModeOnce:
+		if reason != advanceNext && s.playState.currentVideoIndex >= videoLen-1 {
+			// the index stays on the last video, next starts over
+			s.finishLocked()
+			return
+		}
+	}
+	s.playState.currentVideoIndex++
+	if s.playState.currentVideoIndex >= videoLen {
+		s.playState.currentVideoIndex = 0
+	}
+}
+
+// finishLocked stops playing the playlist in once mode, must be called with
+// playStateMu held.
+func (s *Streamer) finishLocked() {
+	s.playState.finished = true
+	s.writeOutput("playlist finished\n")
+}
+
+// shuffleLocked picks a video that hasn't been played since the playlist
+// was last played through, must be called with videoMu and playStateMu held.
+func (s *Streamer) shuffleLocked() {
+	if current := s.playState.currentVideoIndex; current < len(s.videoList) {
+		s.playState.shufflePlayed[s.videoList[current].Path] = true
+	}
+	if index, ok := s.randomIndexLocked(s.playState.shufflePlayed); ok {
+		s.playState.currentVideoIndex = index
+		return
+	}
+	// every video has been played, start another round, the current video
+	// still isn't played twice in a row
+	clear(s.playState.shufflePlayed)
+	if index, ok := s.randomIndexLocked(s.playState.shufflePlayed); ok {
+		s.playState.currentVideoIndex = index
+	}
+}
+
+// randomIndexLocked returns a random video other than the current one that
+// is playable and not in exclude, must be called with videoMu and playStateMu
+// held.
+func (s *Streamer) randomIndexLocked(exclude map[string]bool) (int, bool) {
+	var candidates []int
+	for i, item := range s.videoList {
+		if i == s.playState.currentVideoIndex || exclude[item.Path] {
+			continue
+		}
+		if s.unplayableLocked(item.Path) {
+			continue
+		}
+		candidates = append(candidates, i)
+	}
+	if len(candidates) == 0 {
+		return 0, false
+	}
+	return candidates[rand.IntN(len(candidates))], true
+}
+
+// SetPlayMode changes the order the videos are played in from the next
+// video on.
+func (s *Streamer) SetPlayMode(mode string) error {
+	if !slices.Contains(constant.SupportedPlayModes, mode) {
+		return fmt.Errorf("mode must be one of %s", strings.Join(constant.SupportedPlayModes, ", "))
+	}
+	s.playStateMu.Lock()
+	s.playState.mode = mode
+	clear(s.playState.shufflePlayed)
+	s.playStateMu.Unlock()
+
+	s.writeOutput(fmt.Sprintf("play mode: %s\n", mode))
+	return nil
+}
+
+func (s *Streamer) GetPlayMode() string {
+	s.playStateMu.RLock()
+	defer s.playStateMu.RUnlock()
+	return s.playState.mode
+}
+
+// IsFinished returns whether the playlist was played through in once mode,
+// nothing but queued videos is played until a video is chosen by hand.
+func (s *Streamer) IsFinished() bool {
+	s.playStateMu.RLock()
+	defer s.playStateMu.RUnlock()
+	return s.playState.finished
+}
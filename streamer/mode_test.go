@@ -0,0 +1,189 @@
+package streamer
+
+import (
+	"live-streamer/config"
+	"live-streamer/constant"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAdvanceLocked(t *testing.T) {
+	tests := []struct {
+		name         string
+		mode         string
+		videos       int
+		current      int
+		reason       advanceReason
+		wantCurrent  int
+		wantFinished bool
+	}{
+		{"sequential ended", constant.PlayModeSequential, 3, 0, advanceEnded, 1, false},
+		{"sequential failed", constant.PlayModeSequential, 3, 1, advanceFailed, 2, false},
+		{"sequential wraps", constant.PlayModeSequential, 3, 2, advanceEnded, 0, false},
+		{"empty playlist", constant.PlayModeSequential, 0, 2, advanceEnded, 0, false},
+		{"repeat-one ended", constant.PlayModeRepeatOne, 3, 1, advanceEnded, 1, false},
+		{"repeat-one failed", constant.PlayModeRepeatOne, 3, 1, advanceFailed, 2, false},
+		{"repeat-one next", constant.PlayModeRepeatOne, 3, 2, advanceNext, 0, false},
+		{"once ended", constant.PlayModeOnce, 3, 1, advanceEnded, 2, false},
+		{"once ended last", constant.PlayModeOnce, 3, 2, advanceEnded, 2, true},
+		{"once failed last", constant.PlayModeOnce, 3, 2, advanceFailed, 2, true},
+		{"once next last", constant.PlayModeOnce, 3, 2, advanceNext, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStreamer([]string{"a", "b", "c"}[:tt.videos]...)
+			s.playState.mode = tt.mode
+			s.playState.currentVideoIndex = tt.current
+			s.advanceLocked(tt.reason)
+			if got := s.playState.currentVideoIndex; got != tt.wantCurrent {
+				t.Errorf("current index = %d, want %d", got, tt.wantCurrent)
+			}
+			if got := s.playState.finished; got != tt.wantFinished {
+				t.Errorf("finished = %v, want %v", got, tt.wantFinished)
+			}
+		})
+	}
+}
+
+func TestAdvanceLockedShuffle(t *testing.T) {
+	paths := []string{"a", "b", "c", "d", "e"}
+	s := newTestStreamer(paths...)
+	s.playState.mode = constant.PlayModeShuffle
+	played := map[int]bool{0: true}
+	for range len(paths) - 1 {
+		s.advanceLocked(advanceEnded)
+		current := s.playState.currentVideoIndex
+		if played[current] {
+			t.Fatalf("video %d played twice in a round", current)
+		}
+		played[current] = true
+	}
+	last := s.playState.currentVideoIndex
+	s.advanceLocked(advanceEnded)
+	if s.playState.currentVideoIndex == last {
+		t.Errorf("video %d played twice in a row across rounds", last)
+	}
+}
+
+func TestAdvanceLockedRandom(t *testing.T) {
+	s := newTestStreamer("a", "b", "c")
+	s.playState.mode = constant.PlayModeRandom
+	s.playState.failedVideos["c"] = "failed"
+	for range 20 {
+		s.advanceLocked(advanceEnded)
+		if s.playState.currentVideoIndex == 2 {
+			t.Fatal("failed video picked")
+		}
+	}
+
+	// nothing else to pick, the index stays
+	s.playState.currentVideoIndex = 0
+	s.playState.failedVideos["b"] = "failed"
+	s.advanceLocked(advanceEnded)
+	if s.playState.currentVideoIndex != 0 {
+		t.Errorf("current index = %d, want 0", s.playState.currentVideoIndex)
+	}
+}
+
+// useFakeFFmpeg makes start run an ffmpeg that plays every video to the end
+// at once.
+func useFakeFFmpeg(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg is a shell script")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "ffmpeg"), []byte("#!/bin/sh\nexit 0\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	old := config.Get()
+	config.Set(&config.Config{
+		StateFile: filepath.Join(dir, "state.json"),
+		Outputs:   []config.OutputConfig{{Name: "default", RTMPServer: "rtmp://127.0.0.1/live"}},
+	})
+	t.Cleanup(func() { config.Set(old) })
+}
+
+// A video chosen by hand while nothing is playing must be played once, the
+// playlist goes on after it.
+func TestManualSelectionWhileIdle(t *testing.T) {
+	useFakeFFmpeg(t)
+	tests := []struct {
+		name        string
+		choose      func(s *Streamer)
+		wantCurrent int // after the chosen video ended
+	}{
+		{"next after finished", func(s *Streamer) { s.Next() }, 1},
+		{"prev after finished", func(s *Streamer) { s.Prev() }, 2},
+		{"jump after finished", func(s *Streamer) { _ = s.Jump(0) }, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStreamer("a", "b", "c")
+			s.playState.mode = constant.PlayModeOnce
+			s.playState.currentVideoIndex = 2
+			s.playState.finished = true
+			tt.choose(s)
+			s.start()
+			if got := s.playState.currentVideoIndex; got != tt.wantCurrent {
+				t.Errorf("current index = %d, want %d", got, tt.wantCurrent)
+			}
+			if s.playState.manualControl {
+				t.Error("manual control left set")
+			}
+		})
+	}
+}
+
+// Once mode must stop, not start over, when the videos left can't be played.
+func TestOnceUnplayableLastVideos(t *testing.T) {
+	useFakeFFmpeg(t)
+	tests := []struct {
+		name        string
+		failed      []string
+		wantPlayed  []string
+		wantCurrent int
+	}{
+		{"last failed", []string{"c"}, []string{"a", "b"}, 2},
+		{"last two failed", []string{"b", "c"}, []string{"a"}, 1},
+		{"middle failed", []string{"b"}, []string{"a", "c"}, 2},
+		{"all failed", []string{"a", "b", "c"}, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStreamer("a", "b", "c")
+			s.playState.mode = constant.PlayModeOnce
+			for _, path := range tt.failed {
+				s.playState.failedVideos[path] = "failed"
+			}
+			var played []string
+			for range 5 {
+				if s.IsFinished() {
+					break
+				}
+				s.start()
+				lines, _ := s.GetOutputSince(0)
+				played = nil
+				for _, line := range lines {
+					if path, ok := strings.CutPrefix(line.Text, "stop stream: "); ok {
+						played = append(played, path)
+					}
+				}
+			}
+			if !s.IsFinished() {
+				t.Fatal("playlist not finished")
+			}
+			if !slices.Equal(played, tt.wantPlayed) {
+				t.Errorf("played %v, want %v", played, tt.wantPlayed)
+			}
+			if got := s.playState.currentVideoIndex; got != tt.wantCurrent {
+				t.Errorf("current index = %d, want %d", got, tt.wantCurrent)
+			}
+		})
+	}
+}
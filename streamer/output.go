@@ -0,0 +1,128 @@
+package streamer
+
+import (
+	"errors"
+	"fmt"
+	"live-streamer/config"
+	"strings"
+)
+
+type OutputStatus struct {
+	Name     string `json:"name"`
+	Server   string `json:"server"` // server of the active endpoint
+	Enabled  bool   `json:"enabled"`
+	Endpoint string `json:"endpoint"` // primary or backup
+}
+
+func (s *Streamer) isOutputEnabled(output config.OutputConfig) bool {
+	if enabled, ok := s.outputEnabled[output.Name]; ok {
+		return enabled
+	}
+	return !output.Disabled
+}
+
+// activeOutputLocked returns output with the endpoint currently in use,
+// must be called with destMu held.
+func (s *Streamer) activeOutputLocked(output config.OutputConfig) (config.OutputConfig, bool) {
+	if health, ok := s.outputHealth[output.Name]; ok && health.usingBackup {
+		return output.Backup(), true
+	}
+	return output, false
+}
+
+// enabledOutputs returns the enabled outputs with their active endpoint for
+// the process about to push to them. The one before has ended by then, so
+// the secrets of replaced configs it used don't need redacting anymore.
+func (s *Streamer) enabledOutputs() []config.OutputConfig {
+	config.ForgetReplacedSecrets()
+	s.destMu.RLock()
+	defer s.destMu.RUnlock()
+	var outputs []config.OutputConfig
+	for _, output := range config.Get().Outputs {
+		if s.isOutputEnabled(output) {
+			active, _ := s.activeOutputLocked(output)
+			outputs = append(outputs, active)
+		}
+	}
+	return outputs
+}
+
+func (s *Streamer) GetOutputs() []OutputStatus {
+	s.destMu.RLock()
+	defer s.destMu.RUnlock()
+	var outputs []OutputStatus
+	for _, output := range config.Get().Outputs {
+		active, usingBackup := s.activeOutputLocked(output)
+		endpoint := "primary"
+		if usingBackup {
+			endpoint = "backup"
+		}
+		outputs = append(outputs, OutputStatus{
+			Name:     output.Name,
+			Server:   active.RTMPServer,
+			Enabled:  s.isOutputEnabled(output),
+			Endpoint: endpoint,
+		})
+	}
+	return outputs
+}
+
+// SetOutputEnabled turns a destination on or off, the ffmpeg process
+// pushing to the destinations is restarted to apply the change.
+func (s *Streamer) SetOutputEnabled(name string, enabled bool) error {
+	s.destMu.Lock()
+	found := false
+	enabledCount := 0
+	for _, output := range config.Get().Outputs {
+		if output.Name == name {
+			found = true
+			if s.isOutputEnabled(output) == enabled {
+				s.destMu.Unlock()
+				return nil
+			}
+		} else if s.isOutputEnabled(output) {
+			enabledCount++
+		}
+	}
+	if !found {
+		s.destMu.Unlock()
+		return fmt.Errorf("output %s not found", name)
+	}
+	if !enabled && enabledCount == 0 {
+		s.destMu.Unlock()
+		return errors.New("can not disable the last enabled output")
+	}
+	s.outputEnabled[name] = enabled
+	s.destMu.Unlock()
+
+	s.writeOutput(fmt.Sprintf("output %s enabled: %v\n", name, enabled))
+	s.restartOutput()
+	return nil
+}
+
+// restartOutput restarts the process connected to the rtmp servers
+func (s *Streamer) restartOutput() {
+	if s.publisher != nil {
+		s.publisher.restart()
+		return
+	}
+	s.restartCurrent()
+}
+
+// buildOutputArgs returns the muxer and destination part of the ffmpeg args,
+// several destinations are pushed through the tee muxer and a failing one
+// doesn't affect the others. Streams must be mapped explicitly by the caller.
+func (s *Streamer) buildOutputArgs(outputs []config.OutputConfig) []string {
+	format := config.Get().Play.OutputFormat
+	if len(outputs) == 1 {
+		return []string{"-f", format, outputs[0].URL()}
+	}
+	slaves := make([]string, 0, len(outputs))
+	for _, output := range outputs {
+		slaves = append(slaves, fmt.Sprintf("[f=%s:onfail=ignore]%s", format, output.URL()))
+	}
+	return []string{
+		"-flags", "+global_header",
+		"-f", "tee", strings.Join(slaves, "|"),
+	}
+}
@@ -0,0 +1,131 @@
+package streamer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"live-streamer/config"
+	"live-streamer/utils"
+	"log"
+	"os/exec"
+	"time"
+)
+
+const pausePollInterval = 500 * time.Millisecond
+
+// Pause stops the current video and remembers its position, the slate is
+// streamed instead until Resume is called.
+func (s *Streamer) Pause() error {
+	resume := s.currentResumePoint()
+	s.saveState()
+
+	s.playStateMu.Lock()
+	if s.playState.paused {
+		s.playStateMu.Unlock()
+		return errors.New("already paused")
+	}
+	s.playState.paused = true
+	s.playState.manualControl = true
+	if resume != nil {
+		// otherwise keep the position of a video waiting to be retried
+		s.playState.resume = resume
+	}
+	s.playStateMu.Unlock()
+
+	s.writeOutput("stream paused\n")
+	s.Stop()
+	return nil
+}
+
+// Resume continues the paused video where it was paused
+func (s *Streamer) Resume() error {
+	s.playStateMu.Lock()
+	if !s.playState.paused {
+		s.playStateMu.Unlock()
+		return errors.New("not paused")
+	}
+	s.playState.paused = false
+	s.playStateMu.Unlock()
+
+	s.writeOutput("stream resumed\n")
+	s.Stop() // stop the slate
+	return nil
+}
+
+func (s *Streamer) IsPaused() bool {
+	s.playStateMu.RLock()
+	defer s.playStateMu.RUnlock()
+	return s.playState.paused
+}
+
+// playSlate streams the slate until it is stopped, without a slate it only
+// waits a moment so Stream can check whether it has been resumed.
+func (s *Streamer) playSlate() {
+	slate := config.Get().Play.Slate
+	if slate == "" {
+		time.Sleep(pausePollInterval)
+		return
+	}
+
+	s.playStateMu.Lock()
+	if !s.playState.paused {
+		s.playStateMu.Unlock()
+		return
+	}
+	s.playState.ctx, s.playState.cancel = context.WithCancel(context.Background())
+	cancel := s.playState.cancel
+	s.playState.cmd = exec.CommandContext(s.playState.ctx, "ffmpeg", s.buildSlateArgs(slate)...)
+	s.playState.waitDone = make(chan any)
+	cmd := s.playState.cmd
+	s.playStateMu.Unlock()
+
+	if s.publisher != nil {
+		cmd.Stdout = s.publisher
+	}
+
+	s.writeOutput(fmt.Sprintf("start slate: %s\n", slate))
+	if err := cmd.Start(); err != nil {
+		s.writeOutput(fmt.Sprintf("starting slate error: %v\n", err))
+		time.Sleep(time.Second)
+	} else {
+		_ = cmd.Wait()
+		s.writeOutput(fmt.Sprintf("stop slate: %s\n", slate))
+	}
+	cancel()
+
+	s.playStateMu.Lock()
+	// videos changed while paused are already selected by the index
+	s.playState.manualControl = false
+	close(s.playState.waitDone)
+	s.playStateMu.Unlock()
+}
+
+func (s *Streamer) buildSlateArgs(slate string) []string {
+	args := []string{"-re"}
+	if utils.IsSupportedImage(slate) {
+		args = append(args,
+			"-loop", "1",
+			"-framerate", fmt.Sprintf("%d", config.Get().Play.FrameRate),
+			"-i", slate,
+			"-f", "lavfi",
+			"-i", fmt.Sprintf("anullsrc=r=%d:cl=stereo", config.Get().Play.AudioSampleRate),
+			"-map", "0:v:0", "-map", "1:a:0",
+			"-pix_fmt", "yuv420p",
+		)
+	} else {
+		args = append(args, "-stream_loop", "-1", "-i", slate, "-map", "0:v:0", "-map", "0:a:0?")
+	}
+	args = append(args, s.buildEncodeArgs()...)
+	var tsOffset time.Duration
+	var outputs []config.OutputConfig
+	if s.publisher != nil {
+		tsOffset = s.publisher.offset()
+	} else {
+		outputs = s.enabledOutputs()
+	}
+	args = append(args, s.buildDestinationArgs(tsOffset, outputs)...)
+
+	log.Println("ffmpeg args: ", config.RedactArgs(args))
+
+	return args
+}
@@ -0,0 +1,166 @@
+package streamer
+
+import (
+	"errors"
+	"fmt"
+	"live-streamer/config"
+	"live-streamer/library"
+	"live-streamer/utils"
+	"os"
+	"slices"
+)
+
+// Insert inserts the video at videoPath before index, an index equal to the
+// length of the playlist appends it. The current video keeps playing.
+func (s *Streamer) Insert(index int, videoPath string) error {
+	return s.insert(&index, videoPath)
+}
+
+// Append adds the video at videoPath to the end of the playlist
+func (s *Streamer) Append(videoPath string) error {
+	return s.insert(nil, videoPath)
+}
+
+// insert inserts the video before *at, or appends it when at is nil
+func (s *Streamer) insert(at *int, videoPath string) error {
+	stat, err := os.Stat(videoPath)
+	if err != nil {
+		return err
+	}
+	if stat.IsDir() || !utils.IsSupportedVideo(videoPath) {
+		return fmt.Errorf("%s is not a supported video", videoPath)
+	}
+
+	s.videoMu.Lock()
+	index := len(s.videoList)
+	if at != nil {
+		index = *at
+	}
+	if index < 0 || index > len(s.videoList) {
+		s.videoMu.Unlock()
+		return fmt.Errorf("index %d out of range, there are %d videos", index, len(s.videoList))
+	}
+	if slices.ContainsFunc(s.videoList, func(item config.InputItem) bool { return item.Path == videoPath }) {
+		s.videoMu.Unlock()
+		return fmt.Errorf("video %s is already in the playlist", videoPath)
+	}
+	s.videoList = slices.Insert(s.videoList, index, config.InputItem{Path: videoPath})
+	s.playStateMu.Lock()
+	if index <= s.playState.currentVideoIndex && len(s.videoList) > 1 {
+		s.playState.currentVideoIndex++
+	}
+	delete(s.playState.failedVideos, videoPath)
+	delete(s.playState.inputFailures, videoPath)
+	s.playStateMu.Unlock()
+	s.videoMu.Unlock()
+
+	go library.GlobalLibrary.Get(videoPath)
+	s.writeOutput(fmt.Sprintf("insert video %s at %d\n", videoPath, index))
+	return nil
+}
+
+// RemoveAt removes the video at index, the next video is played if it is
+// the current one.
+func (s *Streamer) RemoveAt(index int) error {
+	s.videoMu.Lock()
+	if index < 0 || index >= len(s.videoList) {
+		s.videoMu.Unlock()
+		return fmt.Errorf("index %d out of range, there are %d videos", index, len(s.videoList))
+	}
+	videoPath := s.videoList[index].Path
+	needStop := s.removeLocked(index)
+	s.videoMu.Unlock()
+
+	s.writeOutput(fmt.Sprintf("remove video %s\n", videoPath))
+	if needStop {
+		s.Stop()
+	}
+	return nil
+}
+
+// removeLocked removes the video at index and keeps the current index on the
+// same video, must be called with videoMu held. It returns whether the
+// removed video is playing and has to be stopped, the video that took its
+// place is played next then.
+func (s *Streamer) removeLocked(index int) bool {
+	videoPath := s.videoList[index].Path
+	s.videoList = slices.Delete(s.videoList, index, index+1)
+
+	s.playStateMu.Lock()
+	defer s.playStateMu.Unlock()
+	delete(s.playState.failedVideos, videoPath)
+	delete(s.playState.inputFailures, videoPath)
+	needStop := false
+	switch {
+	case index < s.playState.currentVideoIndex:
+		s.playState.currentVideoIndex--
+	case index == s.playState.currentVideoIndex:
+		// nothing to stop between videos, while the slate or a queued video
+		// is playing
+		needStop = s.playState.playingPath != "" && s.playState.queuedPath == ""
+		s.playState.manualControl = needStop
+	}
+	if s.playState.currentVideoIndex >= len(s.videoList) {
+		s.playState.currentVideoIndex = 0
+	}
+	return needStop
+}
+
+// Move moves the video at from to index to, the current video keeps playing.
+func (s *Streamer) Move(from, to int) error {
+	s.videoMu.Lock()
+	defer s.videoMu.Unlock()
+	videoLen := len(s.videoList)
+	if from < 0 || from >= videoLen {
+		return fmt.Errorf("index %d out of range, there are %d videos", from, videoLen)
+	}
+	if to < 0 || to >= videoLen {
+		return fmt.Errorf("index %d out of range, there are %d videos", to, videoLen)
+	}
+	if from == to {
+		return nil
+	}
+	item := s.videoList[from]
+	s.videoList = slices.Insert(slices.Delete(s.videoList, from, from+1), to, item)
+
+	s.playStateMu.Lock()
+	current := s.playState.currentVideoIndex
+	switch {
+	case current == from:
+		s.playState.currentVideoIndex = to
+	case from < current && to >= current:
+		s.playState.currentVideoIndex--
+	case from > current && to <= current:
+		s.playState.currentVideoIndex++
+	}
+	s.playStateMu.Unlock()
+
+	s.writeOutput(fmt.Sprintf("move video %s to %d\n", item.Path, to))
+	return nil
+}
+
+// Clear removes every video and stops the current one unless it is queued,
+// videos added later are played from the beginning.
+func (s *Streamer) Clear() error {
+	s.videoMu.Lock()
+	if len(s.videoList) == 0 {
+		s.videoMu.Unlock()
+		return errors.New("playlist is already empty")
+	}
+	s.videoList = nil
+	s.playStateMu.Lock()
+	s.playState.currentVideoIndex = 0
+	s.playState.resume = nil
+	clear(s.playState.failedVideos)
+	clear(s.playState.inputFailures)
+	needStop := s.playState.playingPath != "" && s.playState.queuedPath == ""
+	s.playState.manualControl = needStop
+	s.playStateMu.Unlock()
+	s.videoMu.Unlock()
+
+	s.writeOutput("playlist cleared\n")
+	if needStop {
+		s.Stop()
+	}
+	return nil
+}
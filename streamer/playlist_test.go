@@ -0,0 +1,156 @@
+package streamer
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestMove(t *testing.T) {
+	tests := []struct {
+		name        string
+		current     int
+		from, to    int
+		wantList    []string
+		wantCurrent int
+		wantErr     bool
+	}{
+		{"current moves down", 1, 1, 3, []string{"a", "c", "d", "b"}, 3, false},
+		{"current moves up", 2, 2, 0, []string{"c", "a", "b", "d"}, 0, false},
+		{"from before current to after", 1, 0, 2, []string{"b", "c", "a", "d"}, 0, false},
+		{"from after current to before", 1, 3, 0, []string{"d", "a", "b", "c"}, 2, false},
+		{"onto current from before", 2, 0, 2, []string{"b", "c", "a", "d"}, 1, false},
+		{"onto current from after", 1, 3, 1, []string{"a", "d", "b", "c"}, 2, false},
+		{"both after current", 1, 2, 3, []string{"a", "b", "d", "c"}, 1, false},
+		{"same index", 1, 2, 2, []string{"a", "b", "c", "d"}, 1, false},
+		{"from out of range", 1, 4, 0, []string{"a", "b", "c", "d"}, 1, true},
+		{"to out of range", 1, 0, -1, []string{"a", "b", "c", "d"}, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStreamer("a", "b", "c", "d")
+			s.playState.currentVideoIndex = tt.current
+			err := s.Move(tt.from, tt.to)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Move(%d, %d) error = %v, want error %v", tt.from, tt.to, err, tt.wantErr)
+			}
+			if got := s.GetVideoListPath(); !slices.Equal(got, tt.wantList) {
+				t.Errorf("playlist = %v, want %v", got, tt.wantList)
+			}
+			if got := s.playState.currentVideoIndex; got != tt.wantCurrent {
+				t.Errorf("current index = %d, want %d", got, tt.wantCurrent)
+			}
+		})
+	}
+}
+
+func TestInsert(t *testing.T) {
+	dir := t.TempDir()
+	video := func(name string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	a, b, c, x := video("a.mp4"), video("b.mp4"), video("c.mp4"), video("x.mp4")
+
+	tests := []struct {
+		name        string
+		list        []string
+		current     int
+		index       int
+		path        string
+		wantList    []string
+		wantCurrent int
+		wantErr     bool
+	}{
+		{"before current", []string{a, b, c}, 1, 0, x, []string{x, a, b, c}, 2, false},
+		{"at current", []string{a, b, c}, 1, 1, x, []string{a, x, b, c}, 2, false},
+		{"after current", []string{a, b, c}, 1, 2, x, []string{a, b, x, c}, 1, false},
+		{"append", []string{a, b, c}, 1, 3, x, []string{a, b, c, x}, 1, false},
+		{"into empty playlist", nil, 0, 0, x, []string{x}, 0, false},
+		{"out of range", []string{a, b, c}, 1, 4, x, []string{a, b, c}, 1, true},
+		{"already in playlist", []string{a, b, c}, 1, 0, c, []string{a, b, c}, 1, true},
+		{"not a video", []string{a, b, c}, 1, 0, dir, []string{a, b, c}, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStreamer(tt.list...)
+			s.playState.currentVideoIndex = tt.current
+			err := s.Insert(tt.index, tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Insert(%d, %s) error = %v, want error %v", tt.index, tt.path, err, tt.wantErr)
+			}
+			if got := s.GetVideoListPath(); !slices.Equal(got, tt.wantList) {
+				t.Errorf("playlist = %v, want %v", got, tt.wantList)
+			}
+			if got := s.playState.currentVideoIndex; got != tt.wantCurrent {
+				t.Errorf("current index = %d, want %d", got, tt.wantCurrent)
+			}
+		})
+	}
+}
+
+func TestAppend(t *testing.T) {
+	dir := t.TempDir()
+	x := filepath.Join(dir, "x.mp4")
+	if err := os.WriteFile(x, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := newTestStreamer("a", "b")
+	s.playState.currentVideoIndex = 1
+	if err := s.Append(x); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := s.GetVideoListPath(), []string{"a", "b", x}; !slices.Equal(got, want) {
+		t.Errorf("playlist = %v, want %v", got, want)
+	}
+	if s.playState.currentVideoIndex != 1 {
+		t.Errorf("current index = %d, want 1", s.playState.currentVideoIndex)
+	}
+	if err := s.Append(x); err == nil {
+		t.Error("appended a video already in the playlist")
+	}
+}
+
+func TestRemoveLocked(t *testing.T) {
+	tests := []struct {
+		name        string
+		current     int
+		playing     string
+		queued      string
+		index       int
+		wantList    []string
+		wantCurrent int
+		wantStop    bool
+	}{
+		{"before current", 2, "c", "", 0, []string{"b", "c", "d"}, 1, false},
+		{"after current", 1, "b", "", 3, []string{"a", "b", "c"}, 1, false},
+		{"playing current", 1, "b", "", 1, []string{"a", "c", "d"}, 1, true},
+		{"current between videos", 1, "", "", 1, []string{"a", "c", "d"}, 1, false},
+		{"current while a queued video plays", 1, "q", "q", 1, []string{"a", "c", "d"}, 1, false},
+		{"last playing wraps", 3, "d", "", 3, []string{"a", "b", "c"}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStreamer("a", "b", "c", "d")
+			s.playState.currentVideoIndex = tt.current
+			s.playState.playingPath = tt.playing
+			s.playState.queuedPath = tt.queued
+			if got := s.removeLocked(tt.index); got != tt.wantStop {
+				t.Errorf("removeLocked(%d) = %v, want %v", tt.index, got, tt.wantStop)
+			}
+			if got := s.GetVideoListPath(); !slices.Equal(got, tt.wantList) {
+				t.Errorf("playlist = %v, want %v", got, tt.wantList)
+			}
+			if got := s.playState.currentVideoIndex; got != tt.wantCurrent {
+				t.Errorf("current index = %d, want %d", got, tt.wantCurrent)
+			}
+			if s.playState.manualControl != tt.wantStop {
+				t.Errorf("manual control = %v, want %v", s.playState.manualControl, tt.wantStop)
+			}
+		})
+	}
+}
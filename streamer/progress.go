@@ -0,0 +1,81 @@
+package streamer
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Progress is the playback status reported by ffmpeg's -progress output
+type Progress struct {
+	Frame      int64   `json:"frame"`
+	FPS        float64 `json:"fps"`
+	Bitrate    float64 `json:"bitrate"` // kbit/s
+	TotalSize  int64   `json:"totalSize"`
+	OutTime    float64 `json:"outTime"` // seconds encoded since the video started
+	Speed      float64 `json:"speed"`
+	DupFrames  int64   `json:"dupFrames"`
+	DropFrames int64   `json:"dropFrames"`
+	UpdatedAt  int64   `json:"updatedAt"` // unix milliseconds, 0 if nothing reported yet
+}
+
+var progressKeys = map[string]bool{
+	"frame":       true,
+	"fps":         true,
+	"bitrate":     true,
+	"total_size":  true,
+	"out_time_us": true,
+	"out_time_ms": true,
+	"out_time":    true,
+	"dup_frames":  true,
+	"drop_frames": true,
+	"speed":       true,
+	"progress":    true,
+}
+
+func isProgressKey(key string) bool {
+	return progressKeys[key] || strings.HasPrefix(key, "stream_")
+}
+
+// set parses a single key=value line of the progress output, values
+// ffmpeg reports as N/A are left unchanged.
+func (p *Progress) set(key, value string) {
+	value = strings.TrimSpace(value)
+	switch key {
+	case "frame":
+		p.Frame, _ = strconv.ParseInt(value, 10, 64)
+	case "fps":
+		p.FPS, _ = strconv.ParseFloat(value, 64)
+	case "bitrate":
+		if bitrate, err := strconv.ParseFloat(strings.TrimSuffix(value, "kbits/s"), 64); err == nil {
+			p.Bitrate = bitrate
+		}
+	case "total_size":
+		p.TotalSize, _ = strconv.ParseInt(value, 10, 64)
+	case "out_time_us":
+		if us, err := strconv.ParseInt(value, 10, 64); err == nil {
+			p.OutTime = (time.Duration(us) * time.Microsecond).Seconds()
+		}
+	case "speed":
+		if speed, err := strconv.ParseFloat(strings.TrimSuffix(value, "x"), 64); err == nil {
+			p.Speed = speed
+		}
+	case "dup_frames":
+		p.DupFrames, _ = strconv.ParseInt(value, 10, 64)
+	case "drop_frames":
+		p.DropFrames, _ = strconv.ParseInt(value, 10, 64)
+	}
+}
+
+func (s *Streamer) setProgress(progress Progress) {
+	s.progressMu.Lock()
+	defer s.progressMu.Unlock()
+	s.progress = progress
+}
+
+// GetProgress returns the latest progress of the current video
+func (s *Streamer) GetProgress() Progress {
+	s.progressMu.RLock()
+	defer s.progressMu.RUnlock()
+	return s.progress
+}
@@ -0,0 +1,162 @@
+package streamer
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"live-streamer/config"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// publisher is the long-lived ffmpeg process used in gapless mode.
+// Every video is encoded to mpegts by its own ffmpeg and written into the
+// publisher's stdin, the publisher only remuxes to the rtmp server, so the
+// rtmp session stays up when the video changes.
+type publisher struct {
+	s *Streamer
+
+	mu        sync.Mutex
+	cmd       *exec.Cmd
+	stdin     io.WriteCloser
+	ctx       context.Context
+	cancel    context.CancelFunc
+	epoch     time.Time // start of the timeline, kept across restarts
+	restarted bool      // killed by restart, not a failure
+}
+
+func newPublisher(s *Streamer) *publisher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &publisher{s: s, ctx: ctx, cancel: cancel}
+}
+
+// run keeps the publisher process alive until close is called, it is
+// restarted with a growing backoff while it keeps failing.
+func (p *publisher) run() {
+	failures := 0
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		default:
+		}
+		outputs := p.s.enabledOutputs()
+		startedAt := time.Now()
+		err := p.start(outputs)
+		if time.Since(startedAt) > stableRunTime {
+			failures = 0
+			p.s.outputsSucceeded(outputs, startedAt)
+		}
+		p.mu.Lock()
+		restarted := p.restarted
+		p.restarted = false
+		p.mu.Unlock()
+		wait := time.Second
+		if restarted {
+			wait = 0
+		} else if err != nil {
+			if len(outputs) == 1 && p.s.outputFailed(outputs[0].Name) {
+				failures = 0 // try the backup endpoint right away
+			}
+			failures++
+			wait = backoff(failures)
+			p.s.writeOutput(fmt.Sprintf("publisher error: %v, restart in %v\n", err, wait))
+		}
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (p *publisher) start(outputs []config.OutputConfig) error {
+	args := append([]string{"-fflags", "+genpts", "-f", "mpegts", "-i", "pipe:0", "-map", "0", "-c", "copy"}, p.s.buildOutputArgs(outputs)...)
+	cmd := exec.CommandContext(p.ctx, "ffmpeg", args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.cmd = cmd
+	p.stdin = stdin
+	if p.epoch.IsZero() {
+		p.epoch = time.Now()
+	}
+	p.mu.Unlock()
+
+	p.s.writeOutput("publisher started\n")
+	// stderr must be read to the end before Wait closes it
+	p.log(stderr, outputs)
+	err = cmd.Wait()
+
+	p.mu.Lock()
+	p.cmd = nil
+	p.stdin = nil
+	p.mu.Unlock()
+
+	p.s.writeOutput("publisher stopped\n")
+	return err
+}
+
+func (p *publisher) log(r io.Reader, outputs []config.OutputConfig) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		p.s.checkTeeFailure(scanner.Text(), outputs)
+		if config.Get().Log.PlayState {
+			p.s.writeOutput("[publisher] " + scanner.Text() + "\n")
+		}
+	}
+}
+
+// offset returns the timestamp offset a new encoder must apply to continue
+// the publisher's timeline, videos are played with -re so the wall clock
+// since the first publisher started is a good estimate. The timeline isn't
+// reset when the publisher restarts, the running encoder keeps writing
+// timestamps on it and they must never go backwards.
+func (p *publisher) offset() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.epoch.IsZero() {
+		return 0
+	}
+	return time.Since(p.epoch)
+}
+
+// Write forwards encoded data to the publisher. Data written while the
+// publisher is restarting is dropped, stalling the encoder would freeze
+// the whole playlist.
+func (p *publisher) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	stdin := p.stdin
+	p.mu.Unlock()
+	if stdin != nil {
+		_, _ = stdin.Write(b)
+	}
+	return len(b), nil
+}
+
+// restart kills the publisher process, run starts a new one with fresh args
+func (p *publisher) restart() {
+	p.mu.Lock()
+	cmd := p.cmd
+	p.restarted = cmd != nil
+	p.mu.Unlock()
+	if cmd != nil && cmd.Process != nil {
+		_ = cmd.Process.Kill()
+	}
+}
+
+func (p *publisher) close() {
+	p.cancel()
+}
@@ -0,0 +1,87 @@
+package streamer
+
+import (
+	"errors"
+	"fmt"
+	"live-streamer/library"
+	"live-streamer/utils"
+	"os"
+	"slices"
+)
+
+// Enqueue adds the video at videoPath to the up next queue, queued videos are
+// played before the playlist continues and don't need to be in it.
+func (s *Streamer) Enqueue(videoPath string) error {
+	stat, err := os.Stat(videoPath)
+	if err != nil {
+		return err
+	}
+	if stat.IsDir() || !utils.IsSupportedVideo(videoPath) {
+		return fmt.Errorf("%s is not a supported video", videoPath)
+	}
+
+	s.playStateMu.Lock()
+	s.playState.queue = append(s.playState.queue, videoPath)
+	s.playStateMu.Unlock()
+
+	s.writeOutput(fmt.Sprintf("queue video %s\n", videoPath))
+	return nil
+}
+
+// RemoveFromQueue removes the queued video at index, the queued video that
+// is playing isn't in the queue anymore.
+func (s *Streamer) RemoveFromQueue(index int) error {
+	s.playStateMu.Lock()
+	defer s.playStateMu.Unlock()
+	if index < 0 || index >= len(s.playState.queue) {
+		return fmt.Errorf("index %d out of range, there are %d queued videos", index, len(s.playState.queue))
+	}
+	s.playState.queue = slices.Delete(s.playState.queue, index, index+1)
+	return nil
+}
+
+// ClearQueue removes every queued video, the playlist continues after the
+// video that is playing.
+func (s *Streamer) ClearQueue() error {
+	s.playStateMu.Lock()
+	defer s.playStateMu.Unlock()
+	if len(s.playState.queue) == 0 {
+		return errors.New("queue is already empty")
+	}
+	s.playState.queue = nil
+	return nil
+}
+
+// GetQueue returns the videos that are played before the playlist continues
+func (s *Streamer) GetQueue() []string {
+	s.playStateMu.RLock()
+	defer s.playStateMu.RUnlock()
+	return append([]string{}, s.playState.queue...)
+}
+
+func (s *Streamer) queueLen() int {
+	s.playStateMu.RLock()
+	defer s.playStateMu.RUnlock()
+	return len(s.playState.queue)
+}
+
+// nextQueuedLocked returns the queued video start should play, it is taken
+// from the queue unless a queued video is being restarted. Must be called
+// with playStateMu held.
+func (s *Streamer) nextQueuedLocked() (string, bool) {
+	if s.playState.skipQueue {
+		s.playState.skipQueue = false
+		s.playState.queuedPath = ""
+		return "", false
+	}
+	for s.playState.queuedPath == "" && len(s.playState.queue) > 0 {
+		videoPath := s.playState.queue[0]
+		s.playState.queue = s.playState.queue[1:]
+		if reason, bad := library.GlobalLibrary.Bad(videoPath); bad {
+			s.writeOutput(fmt.Sprintf("skip queued %s: %s\n", videoPath, reason))
+			continue
+		}
+		s.playState.queuedPath = videoPath
+	}
+	return s.playState.queuedPath, s.playState.queuedPath != ""
+}
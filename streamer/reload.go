@@ -0,0 +1,116 @@
+package streamer
+
+import (
+	"fmt"
+	"live-streamer/config"
+	"log"
+	"reflect"
+	"slices"
+)
+
+// ApplyConfig applies a reloaded config. Videos added to or removed from
+// the inputs are added to or removed from the playlist, videos added by hand
+// are kept. Play settings take effect from the next video, changed outputs
+// restart the process pushing to the rtmp servers.
+func (s *Streamer) ApplyConfig(old, c *config.Config) {
+	s.applyVideoList(old.VideoList, c.VideoList)
+
+	if c.Play.Mode != old.Play.Mode {
+		if err := s.SetPlayMode(c.Play.Mode); err != nil {
+			s.writeOutput(fmt.Sprintf("set play mode error: %v\n", err))
+		}
+	}
+
+	if !reflect.DeepEqual(old.Outputs, c.Outputs) {
+		s.applyOutputs(old.Outputs, c.Outputs)
+	}
+
+	var restart []string
+	if c.Server.Addr != old.Server.Addr {
+		restart = append(restart, "server.addr")
+	}
+	if c.Log.MaxLines != old.Log.MaxLines {
+		restart = append(restart, "log.max_lines")
+	}
+	if c.Play.Gapless != old.Play.Gapless {
+		restart = append(restart, "play.gapless")
+	}
+	if c.StateFile != old.StateFile {
+		restart = append(restart, "state_file")
+	}
+	if c.MediaCache != old.MediaCache {
+		restart = append(restart, "media_cache")
+	}
+	if c.ControlSocket != old.ControlSocket {
+		restart = append(restart, "control_socket")
+	}
+	for _, key := range restart {
+		s.writeOutput(fmt.Sprintf("config %s changed, restart to apply it\n", key))
+	}
+	s.writeOutput("config reloaded\n")
+}
+
+// ReportConfigError reports a rejected config, the running config is kept
+func (s *Streamer) ReportConfigError(err error) {
+	log.Printf("config reload rejected: %v", err)
+	s.writeOutput(fmt.Sprintf("config reload rejected, keeping the running config: %v\n", err))
+}
+
+func (s *Streamer) applyVideoList(oldList, newList []config.InputItem) {
+	oldPaths := make(map[string]bool, len(oldList))
+	for _, item := range oldList {
+		oldPaths[item.Path] = true
+	}
+	newItems := make(map[string]config.InputItem, len(newList))
+	for _, item := range newList {
+		newItems[item.Path] = item
+	}
+
+	var added []string
+	var removed []string
+	s.videoMu.Lock()
+	present := make(map[string]bool, len(s.videoList))
+	for i, item := range s.videoList {
+		present[item.Path] = true
+		if newItem, ok := newItems[item.Path]; ok {
+			// start and end may have changed
+			s.videoList[i] = newItem
+		} else if oldPaths[item.Path] {
+			removed = append(removed, item.Path)
+		}
+	}
+	for _, item := range newList {
+		if !present[item.Path] {
+			s.videoList = append(s.videoList, item)
+			added = append(added, item.Path)
+		}
+	}
+	s.videoMu.Unlock()
+
+	for _, path := range removed {
+		s.Remove(path)
+	}
+	for _, path := range added {
+		s.videoAdded(path)
+	}
+	if len(added) > 0 || len(removed) > 0 {
+		s.writeOutput(fmt.Sprintf("playlist reloaded: %d added, %d removed\n", len(added), len(removed)))
+	}
+}
+
+func (s *Streamer) applyOutputs(oldOutputs, newOutputs []config.OutputConfig) {
+	s.destMu.Lock()
+	for _, output := range oldOutputs {
+		index := slices.IndexFunc(newOutputs, func(o config.OutputConfig) bool { return o.Name == output.Name })
+		if index >= 0 && newOutputs[index] == output {
+			continue
+		}
+		// removed or changed, runtime state of the old endpoint is dropped
+		delete(s.outputEnabled, output.Name)
+		delete(s.outputHealth, output.Name)
+	}
+	s.destMu.Unlock()
+
+	s.writeOutput("outputs changed\n")
+	s.restartOutput()
+}
@@ -0,0 +1,146 @@
+package streamer
+
+import (
+	"fmt"
+	"live-streamer/config"
+	"live-streamer/library"
+	"time"
+)
+
+// ScheduleStatus is a program of the schedule with when it airs next
+type ScheduleStatus struct {
+	Name      string   `json:"name"`
+	At        string   `json:"at"`
+	Days      []string `json:"days,omitempty"`
+	HardStart bool     `json:"hardStart"`
+	Next      int64    `json:"next"` // unix milliseconds
+}
+
+const scheduleCheckInterval = time.Second
+
+// scheduleOccurrence returns the start of the program on the day of t, the
+// wall clock time is kept on days the clocks change. A time skipped when the
+// clocks go forward is moved past the change.
+func scheduleOccurrence(schedule config.ScheduleConfig, t time.Time) time.Time {
+	start := time.Date(t.Year(), t.Month(), t.Day(), schedule.Hour, schedule.Minute, schedule.Second, 0, t.Location())
+	hour, minute, second := start.Clock()
+	skipped := time.Duration(schedule.Hour-hour)*time.Hour +
+		time.Duration(schedule.Minute-minute)*time.Minute +
+		time.Duration(schedule.Second-second)*time.Second
+	if skipped > 0 {
+		start = start.Add(skipped)
+	}
+	return start
+}
+
+// nextScheduleTime returns when the program starts next after t
+func nextScheduleTime(schedule config.ScheduleConfig, t time.Time) time.Time {
+	for i := 0; i <= 7; i++ {
+		day := t.AddDate(0, 0, i)
+		if !schedule.OnDay(day.Weekday()) {
+			continue
+		}
+		if start := scheduleOccurrence(schedule, day); start.After(t) {
+			return start
+		}
+	}
+	return time.Time{}
+}
+
+// runSchedule starts the programs of the schedule when they are due, programs
+// due while live-streamer wasn't running are not made up for.
+func (s *Streamer) runSchedule() {
+	ticker := time.NewTicker(scheduleCheckInterval)
+	defer ticker.Stop()
+	last := time.Now()
+	for now := range ticker.C {
+		for _, schedule := range config.Get().Schedule {
+			if scheduleDue(schedule, last, now) {
+				s.startSchedule(schedule)
+			}
+		}
+		last = now
+	}
+}
+
+// scheduleDue returns whether the program starts after last and by now, the
+// day of last is checked too in case the checks span midnight.
+func scheduleDue(schedule config.ScheduleConfig, last, now time.Time) bool {
+	for _, day := range []time.Time{last, now} {
+		if !schedule.OnDay(day.Weekday()) {
+			continue
+		}
+		start := scheduleOccurrence(schedule, day)
+		if start.After(last) && !start.After(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// startSchedule puts the videos of the program in front of the queue, the
+// playing video is cut when the program starts hard.
+func (s *Streamer) startSchedule(schedule config.ScheduleConfig) {
+	videos, err := schedule.Videos()
+	if err != nil {
+		s.writeOutput(fmt.Sprintf("schedule %s error: %v\n", schedule.Name, err))
+		return
+	}
+	if len(videos) == 0 {
+		s.writeOutput(fmt.Sprintf("schedule %s has no videos\n", schedule.Name))
+		return
+	}
+	paths := make([]string, 0, len(videos))
+	for _, video := range videos {
+		if reason, bad := library.GlobalLibrary.Bad(video.Path); bad {
+			s.writeOutput(fmt.Sprintf("schedule %s skips %s: %s\n", schedule.Name, video.Path, reason))
+			continue
+		}
+		paths = append(paths, video.Path)
+	}
+	if len(paths) == 0 {
+		s.writeOutput(fmt.Sprintf("schedule %s has no playable videos\n", schedule.Name))
+		return
+	}
+
+	s.videoMu.RLock()
+	s.playStateMu.Lock()
+	s.playState.queue = append(paths, s.playState.queue...)
+	// the slate keeps playing while paused, the program starts on resume
+	cut := schedule.HardStart && s.playState.playingPath != "" && !s.playState.paused
+	if cut {
+		if s.playState.queuedPath != "" {
+			s.playState.queuedPath = ""
+		} else {
+			// the cut video counts as played to the end
+			s.advanceLocked(advanceEnded)
+		}
+		s.playState.manualControl = true
+	}
+	s.playStateMu.Unlock()
+	s.videoMu.RUnlock()
+
+	s.writeOutput(fmt.Sprintf("schedule %s started: %d videos\n", schedule.Name, len(paths)))
+	if cut {
+		s.Stop()
+	}
+}
+
+// GetSchedule returns the programs of the schedule
+func (s *Streamer) GetSchedule() []ScheduleStatus {
+	now := time.Now()
+	schedules := []ScheduleStatus{}
+	for _, schedule := range config.Get().Schedule {
+		status := ScheduleStatus{
+			Name:      schedule.Name,
+			At:        schedule.At,
+			Days:      schedule.Days,
+			HardStart: schedule.HardStart,
+		}
+		if next := nextScheduleTime(schedule, now); !next.IsZero() {
+			status.Next = next.UnixMilli()
+		}
+		schedules = append(schedules, status)
+	}
+	return schedules
+}
@@ -0,0 +1,94 @@
+package streamer
+
+import (
+	"live-streamer/config"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestNextScheduleTime(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2026, month, day, hour, min, 0, 0, loc)
+	}
+	daily := config.ScheduleConfig{Hour: 8}
+	weekdays := config.ScheduleConfig{Hour: 8, Weekdays: []time.Weekday{time.Monday, time.Friday}}
+	late := config.ScheduleConfig{Hour: 23, Minute: 30, Second: 15}
+	inGap := config.ScheduleConfig{Hour: 2, Minute: 30}
+
+	tests := []struct {
+		name     string
+		schedule config.ScheduleConfig
+		now      time.Time
+		want     time.Time
+	}{
+		{"later today", daily, at(10, 19, 7, 0), at(10, 19, 8, 0)},
+		{"passed today", daily, at(10, 19, 9, 0), at(10, 20, 8, 0)},
+		{"exactly now", daily, at(10, 19, 8, 0), at(10, 20, 8, 0)},
+		{"seconds", late, at(10, 19, 23, 30), time.Date(2026, 10, 19, 23, 30, 15, 0, loc)},
+		{"next weekday", weekdays, at(10, 17, 10, 0), at(10, 19, 8, 0)},
+		{"passed today, next weekday", weekdays, at(10, 19, 9, 0), at(10, 23, 8, 0)},
+		{"over the weekend", weekdays, at(10, 23, 9, 0), at(10, 26, 8, 0)},
+		{"clocks go forward", daily, at(3, 8, 0, 30), at(3, 8, 8, 0)},
+		{"clocks go back", daily, at(11, 1, 0, 30), at(11, 1, 8, 0)},
+		{"before the clocks go back", daily, at(10, 31, 9, 0), at(11, 1, 8, 0)},
+		// 02:30 doesn't exist that day, it is moved past the change
+		{"in the skipped hour", inGap, at(3, 8, 0, 30), at(3, 8, 3, 30)},
+		{"after the skipped hour", inGap, at(3, 8, 3, 0), at(3, 8, 3, 30)},
+		{"day after the skipped hour", inGap, at(3, 8, 4, 0), at(3, 9, 2, 30)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := nextScheduleTime(tt.schedule, tt.now)
+			if !got.Equal(tt.want) {
+				t.Errorf("nextScheduleTime(%v) = %v, want %v", tt.now, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduleDue(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(month time.Month, day, hour, min, sec int) time.Time {
+		return time.Date(2026, month, day, hour, min, sec, 0, loc)
+	}
+	daily := config.ScheduleConfig{Hour: 8}
+	mondays := config.ScheduleConfig{Hour: 8, Weekdays: []time.Weekday{time.Monday}}
+	midnight := config.ScheduleConfig{}
+	lastSecond := config.ScheduleConfig{Hour: 23, Minute: 59, Second: 59}
+
+	tests := []struct {
+		name      string
+		schedule  config.ScheduleConfig
+		last, now time.Time
+		want      bool
+	}{
+		{"starts in the window", daily, at(10, 19, 7, 59, 59), at(10, 19, 8, 0, 0), true},
+		{"starts at last", daily, at(10, 19, 8, 0, 0), at(10, 19, 8, 0, 1), false},
+		{"not yet", daily, at(10, 19, 7, 59, 58), at(10, 19, 7, 59, 59), false},
+		{"already started", daily, at(10, 19, 8, 0, 1), at(10, 19, 8, 0, 2), false},
+		{"long window", daily, at(10, 19, 7, 0, 0), at(10, 19, 9, 0, 0), true},
+		{"on its day", mondays, at(10, 19, 7, 59, 59), at(10, 19, 8, 0, 0), true},
+		{"not on its day", mondays, at(10, 20, 7, 59, 59), at(10, 20, 8, 0, 0), false},
+		{"at midnight", midnight, at(10, 19, 23, 59, 59), at(10, 20, 0, 0, 0), true},
+		{"window spans midnight", lastSecond, at(10, 19, 23, 59, 58), at(10, 20, 0, 0, 1), true},
+		{"clocks go forward", daily, at(3, 8, 7, 59, 59), at(3, 8, 8, 0, 0), true},
+		{"an hour off when the clocks go forward", daily, at(3, 8, 8, 59, 59), at(3, 8, 9, 0, 0), false},
+		{"clocks go back", daily, at(11, 1, 7, 59, 59), at(11, 1, 8, 0, 0), true},
+		{"an hour off when the clocks go back", daily, at(11, 1, 6, 59, 59), at(11, 1, 7, 0, 0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scheduleDue(tt.schedule, tt.last, tt.now); got != tt.want {
+				t.Errorf("scheduleDue(%v, %v) = %v, want %v", tt.last, tt.now, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package streamer
+
+import (
+	"errors"
+	"fmt"
+	"live-streamer/utils"
+	"strings"
+)
+
+// Seek restarts the current video at target, which is either an absolute
+// position like "00:10:00" or relative to the current position like "+30s"
+// or "-10".
+func (s *Streamer) Seek(target string) error {
+	target = strings.TrimSpace(target)
+	offset, err := utils.ParseDuration(target)
+	if err != nil {
+		return err
+	}
+	relative := strings.HasPrefix(target, "+") || strings.HasPrefix(target, "-")
+
+	s.playStateMu.Lock()
+	if s.playState.paused {
+		// move the position the paused video will resume from
+		defer s.playStateMu.Unlock()
+		if s.playState.resume == nil {
+			return errors.New("nothing to seek")
+		}
+		if relative {
+			offset += s.playState.resume.offset
+		}
+		s.playState.resume.offset = max(offset, 0)
+		return nil
+	}
+	s.playStateMu.Unlock()
+
+	current := s.currentResumePoint()
+	if current == nil {
+		return errors.New("nothing is playing")
+	}
+	if relative {
+		offset += current.offset
+	}
+	current.offset = max(offset, 0)
+
+	s.playStateMu.Lock()
+	s.playState.manualControl = true
+	s.playState.resume = current
+	s.playStateMu.Unlock()
+
+	s.writeOutput(fmt.Sprintf("seek %s to %s\n", current.path, utils.FormatDuration(current.offset)))
+	s.Stop()
+	return nil
+}
@@ -0,0 +1,122 @@
+package streamer
+
+import (
+	"encoding/json"
+	"fmt"
+	"live-streamer/config"
+	"log"
+	"os"
+	"time"
+)
+
+// resumePoint is a position to continue playing a video from
+type resumePoint struct {
+	path   string
+	offset time.Duration
+}
+
+// savedState is the content of the state file, the video is stored by
+// path because indexes change whenever the playlist does.
+type savedState struct {
+	Path      string  `json:"path"`
+	Offset    float64 `json:"offset"` // seconds
+	UpdatedAt int64   `json:"updatedAt"`
+}
+
+const stateSaveInterval = 5 * time.Second
+
+// currentResumePoint returns the position of the playing video, nil if
+// nothing is playing.
+func (s *Streamer) currentResumePoint() *resumePoint {
+	s.playStateMu.RLock()
+	defer s.playStateMu.RUnlock()
+	if s.playState.playingPath == "" || s.playState.startedAt.IsZero() || s.playState.closing {
+		return nil
+	}
+	return &resumePoint{
+		path:   s.playState.playingPath,
+		offset: s.positionLocked(),
+	}
+}
+
+// restartCurrent restarts ffmpeg on the playing video at its current position,
+// or the slate. The position of a paused or retried video is kept.
+func (s *Streamer) restartCurrent() {
+	resume := s.currentResumePoint()
+	s.playStateMu.Lock()
+	if s.playState.playingPath != "" {
+		s.playState.manualControl = true
+	}
+	if resume != nil {
+		s.playState.resume = resume
+	}
+	s.playStateMu.Unlock()
+	s.Stop()
+}
+
+func (s *Streamer) saveState() {
+	resume := s.currentResumePoint()
+	if resume == nil {
+		return
+	}
+	data, err := json.Marshal(savedState{
+		Path:      resume.path,
+		Offset:    resume.offset.Seconds(),
+		UpdatedAt: time.Now().Unix(),
+	})
+	if err != nil {
+		log.Printf("marshal state error: %v", err)
+		return
+	}
+	// write to a temp file first so a crash never leaves a truncated state
+	tmp := config.Get().StateFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		log.Printf("writing state file error: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, config.Get().StateFile); err != nil {
+		log.Printf("writing state file error: %v", err)
+	}
+}
+
+// loadState restores the video and position saved by the last run
+func (s *Streamer) loadState() {
+	data, err := os.ReadFile(config.Get().StateFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("reading state file error: %v", err)
+		}
+		return
+	}
+	var state savedState
+	if err := json.Unmarshal(data, &state); err != nil {
+		log.Printf("state file is invalid, ignored: %v", err)
+		return
+	}
+
+	s.videoMu.RLock()
+	defer s.videoMu.RUnlock()
+	for i, item := range s.videoList {
+		if item.Path == state.Path {
+			s.playStateMu.Lock()
+			s.playState.currentVideoIndex = i
+			s.playState.resume = &resumePoint{
+				path:   state.Path,
+				offset: time.Duration(state.Offset * float64(time.Second)),
+			}
+			s.playStateMu.Unlock()
+			s.writeOutput(fmt.Sprintf("resume stream: %s at %.0fs\n", state.Path, state.Offset))
+			return
+		}
+	}
+	log.Printf("saved video %s not found, start from the beginning", state.Path)
+}
+
+// persistState saves the state periodically so a crash loses little progress
+func (s *Streamer) persistState() {
+	ticker := time.NewTicker(stateSaveInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.saveState()
+	}
+}
@@ -0,0 +1,42 @@
+package streamer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRestartCurrent(t *testing.T) {
+	saved := &resumePoint{path: "b", offset: 42 * time.Second}
+	tests := []struct {
+		name        string
+		playing     string
+		paused      bool
+		wantPath    string
+		wantManual  bool
+		wantAtLeast time.Duration
+	}{
+		{"playing", "a", false, "a", true, 10 * time.Second},
+		{"paused", "", true, "b", false, saved.offset},
+		{"waiting to retry", "", false, "b", false, saved.offset},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStreamer("a", "b")
+			s.playState.resume = saved
+			s.playState.paused = tt.paused
+			s.playState.playingPath = tt.playing
+			if tt.playing != "" {
+				s.playState.startOffset = 10 * time.Second
+				s.playState.startedAt = time.Now()
+			}
+			s.restartCurrent()
+			resume := s.playState.resume
+			if resume == nil || resume.path != tt.wantPath || resume.offset < tt.wantAtLeast {
+				t.Errorf("resume = %+v, want %s from %v", resume, tt.wantPath, tt.wantAtLeast)
+			}
+			if s.playState.manualControl != tt.wantManual {
+				t.Errorf("manual control = %v, want %v", s.playState.manualControl, tt.wantManual)
+			}
+		})
+	}
+}
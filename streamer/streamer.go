@@ -6,9 +6,13 @@ import (
 	"fmt"
 	"io"
 	"live-streamer/config"
+	"live-streamer/constant"
+	"live-streamer/library"
+	"live-streamer/utils"
 	"log"
 	"os"
 	"os/exec"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -21,6 +25,23 @@ type playState struct {
 	ctx               context.Context
 	cancel            context.CancelFunc
 	waitDone          chan any
+	playingPath       string        // video ffmpeg is playing, empty between videos
+	startOffset       time.Duration // position in the video ffmpeg started at
+	startedAt         time.Time
+	resume            *resumePoint // consumed by start when the video matches
+	paused            bool
+	closing           bool          // set by Close, no more videos are started
+	tsOffset          time.Duration // timestamp offset of the video in gapless mode
+	outputFailures    int           // consecutive output failures
+	retryAt           time.Time     // no video is started before
+	inputFailures     map[string]int
+	failedVideos      map[string]string // videos that keep failing, with the reason
+	queue             []string          // up next videos, played before the index advances
+	queuedPath        string            // queued video taken from the queue, kept until it ends
+	skipQueue         bool              // the next video is chosen from the playlist by hand
+	mode              string            // one of constant.SupportedPlayModes
+	shufflePlayed     map[string]bool   // videos played in the current shuffle round
+	finished          bool              // played through in once mode
 }
 
 type Streamer struct {
@@ -30,73 +51,229 @@ type Streamer struct {
 	videoMu   sync.RWMutex
 	videoList []config.InputItem
 
-	outputMu sync.RWMutex
-	output   strings.Builder
+	output *logBuffer
+
+	progressMu sync.RWMutex
+	progress   Progress
+
+	destMu        sync.RWMutex
+	outputEnabled map[string]bool // runtime overrides of OutputConfig.Disabled
+	outputHealth  map[string]*outputHealth
+
+	publisher *publisher // nil unless gapless mode is enabled
 }
 
 var GlobalStreamer *Streamer
 
+// lines of stderr kept to tell why ffmpeg failed
+const stderrTailLines = 20
+
 func NewStreamer(videoList []config.InputItem) *Streamer {
 	GlobalStreamer = &Streamer{
-		videoList: videoList,
-		playState: playState{},
-		output:    strings.Builder{},
+		videoList: slices.Clone(videoList),
+		playState: playState{
+			inputFailures: make(map[string]int),
+			failedVideos:  make(map[string]string),
+			mode:          config.Get().Play.Mode,
+			shufflePlayed: make(map[string]bool),
+		},
+		output: newLogBuffer(config.Get().Log.MaxLines),
+
+		outputEnabled: make(map[string]bool),
+		outputHealth:  make(map[string]*outputHealth),
+	}
+	if config.Get().Play.Gapless {
+		GlobalStreamer.publisher = newPublisher(GlobalStreamer)
 	}
+	GlobalStreamer.loadState()
 	return GlobalStreamer
 }
 
 func (s *Streamer) start() {
+	s.videoMu.RLock()
 	s.playStateMu.Lock()
+	if s.playState.closing {
+		s.playStateMu.Unlock()
+		s.videoMu.RUnlock()
+		return
+	}
+	if s.playState.paused {
+		// paused before this video started, nothing to stop
+		s.playState.manualControl = false
+		s.playStateMu.Unlock()
+		s.videoMu.RUnlock()
+		return
+	}
+	var currentVideo config.InputItem
+	if queuedPath, ok := s.nextQueuedLocked(); ok {
+		// the index stays where the playlist continues afterwards
+		currentVideo = config.InputItem{Path: queuedPath}
+	} else {
+		if s.playState.finished {
+			// nothing left to play until a video is chosen by hand
+			s.playStateMu.Unlock()
+			s.videoMu.RUnlock()
+			return
+		}
+		index, ok := s.playableIndexLocked()
+		if !ok && s.playState.mode == constant.PlayModeOnce {
+			// the videos left are failed or bad media
+			s.finishLocked()
+			s.playStateMu.Unlock()
+			s.videoMu.RUnlock()
+			return
+		}
+		if !ok {
+			s.playStateMu.Unlock()
+			s.videoMu.RUnlock()
+			// every video failed, wait for the playlist to change
+			time.Sleep(time.Second)
+			return
+		}
+		s.playState.currentVideoIndex = index
+		currentVideo = s.videoList[index]
+	}
+	// a video chosen by hand while nothing was playing is the one picked
+	// now, only a change during this video must keep the index
+	s.playState.manualControl = false
 	s.playState.ctx, s.playState.cancel = context.WithCancel(context.Background())
+	ctx := s.playState.ctx
 	cancel := s.playState.cancel
-	currentVideo := s.videoList[s.playState.currentVideoIndex]
 	videoPath := currentVideo.Path
-	s.playState.cmd = exec.CommandContext(s.playState.ctx, "ffmpeg", s.buildFFmpegArgs(currentVideo)...)
+	if resume := s.playState.resume; resume != nil {
+		s.playState.resume = nil
+		if resume.path == videoPath {
+			currentVideo.Start = utils.FormatDuration(resume.offset)
+		}
+	}
+	s.playState.startOffset = 0
+	if currentVideo.Start != "" {
+		if offset, err := utils.ParseDuration(currentVideo.Start); err == nil {
+			s.playState.startOffset = offset
+		}
+	}
+	s.playState.playingPath = videoPath
+	s.playState.tsOffset = 0
+	if s.publisher != nil {
+		s.playState.tsOffset = s.publisher.offset()
+	}
+	tsOffset := s.playState.tsOffset
+	var outputs []config.OutputConfig // pushed to directly unless gapless
+	if s.publisher == nil {
+		outputs = s.enabledOutputs()
+	}
+	s.playState.cmd = exec.CommandContext(ctx, "ffmpeg", s.buildFFmpegArgs(currentVideo, tsOffset, outputs)...)
 	s.playState.waitDone = make(chan any)
 	cmd := s.playState.cmd
 	s.playStateMu.Unlock()
+	s.videoMu.RUnlock()
+
+	if s.publisher != nil {
+		cmd.Stdout = s.publisher
+	}
 
 	s.writeOutput(fmt.Sprintln("start stream: ", videoPath))
 
+	var stderr []string
+	var err error
 	pipe, err := cmd.StderrPipe()
-	if err != nil {
-		log.Printf("failed to get pipe: %v", err)
-		return
+	if err == nil {
+		s.setProgress(Progress{})
+		err = cmd.Start()
 	}
+	startedAt := time.Now()
+	if err == nil {
+		s.playStateMu.Lock()
+		s.playState.startedAt = startedAt
+		s.playStateMu.Unlock()
+		s.saveState()
 
-	reader := bufio.NewReader(pipe)
-
-	if err := cmd.Start(); err != nil {
+		// stderr must be read to the end before Wait closes it
+		stderr = s.log(pipe, videoPath, tsOffset, outputs)
+		err = cmd.Wait()
+		s.writeOutput(fmt.Sprintf("stop stream: %s\n", videoPath))
+	} else {
+		// ffmpeg couldn't be run at all, worth retrying like a network error
+		stderr = []string{err.Error()}
 		s.writeOutput(fmt.Sprintf("starting ffmpeg error: %v\n", err))
-		return
 	}
-
-	go s.log(reader)
-
-	_ = cmd.Wait()
+	stopped := ctx.Err() != nil // stopped by Stop, not a failure
 	cancel()
 
-	s.writeOutput(fmt.Sprintf("stop stream: %s\n", videoPath))
+	kind := failureNone
+	if err != nil && !stopped {
+		if cmd.Process == nil {
+			kind = failureOutput
+		} else {
+			kind = classifyFailure(stderr)
+		}
+		s.writeFailure(videoPath, kind, err)
+	}
+	switched := false
+	if kind == failureOutput && len(outputs) == 1 {
+		switched = s.outputFailed(outputs[0].Name)
+	} else if kind == failureNone && time.Since(startedAt) > stableRunTime {
+		s.outputsSucceeded(outputs, startedAt)
+	}
 
+	s.videoMu.RLock()
 	s.playStateMu.Lock()
-	if s.playState.manualControl {
+	position := s.positionLocked()
+	s.playState.playingPath = ""
+	s.playState.startedAt = time.Time{}
+	switch {
+	case s.playState.manualControl:
 		// manualing change video, don't increase currentVideoIndex
 		s.playState.manualControl = false
-	} else {
-		s.playState.currentVideoIndex++
-		s.videoMu.RLock()
-		if s.playState.currentVideoIndex >= len(s.videoList) {
-			s.playState.currentVideoIndex = 0
+	case kind == failureOutput:
+		// play the same video from where it failed once the server is back
+		if switched {
+			// the backup endpoint deserves an immediate try
+			s.playState.outputFailures = 0
+		}
+		wait := s.retryOutputLocked(&resumePoint{path: videoPath, offset: position}, time.Since(startedAt))
+		s.writeOutput(fmt.Sprintf("retry %s in %v\n", videoPath, wait))
+	case s.playState.queuedPath != "":
+		// back to the playlist, the index wasn't advanced for the queued video
+		s.playState.queuedPath = ""
+		s.playState.outputFailures = 0
+	default:
+		if kind == failureInput {
+			if s.recordInputFailureLocked(videoPath, lastLine(stderr)) {
+				s.writeOutput(fmt.Sprintf("skip %s from now on: failed %d times\n", videoPath, config.Get().Retry.MaxInputFailures))
+			}
+		} else {
+			delete(s.playState.inputFailures, videoPath)
+			s.playState.outputFailures = 0
+		}
+		if kind == failureInput {
+			s.advanceLocked(advanceFailed)
+		} else {
+			s.advanceLocked(advanceEnded)
 		}
-		s.videoMu.RUnlock()
 	}
 	close(s.playState.waitDone)
 	s.playStateMu.Unlock()
+	s.videoMu.RUnlock()
 }
 
 func (s *Streamer) Stream() {
+	if s.publisher != nil {
+		go s.publisher.run()
+	}
+	go s.persistState()
+	go s.watchPrimaries()
+	go s.runSchedule()
 	for {
-		if len(s.videoList) == 0 {
+		if s.IsPaused() {
+			s.playSlate()
+			continue
+		}
+		if wait := s.retryWait(); wait > 0 {
+			time.Sleep(min(wait, pausePollInterval))
+			continue
+		}
+		if (s.videoLen() == 0 || s.IsFinished()) && s.queueLen() == 0 {
 			time.Sleep(time.Second)
 			continue
 		}
@@ -130,36 +307,34 @@ func (s *Streamer) Stop() {
 
 func (s *Streamer) Add(videoPath string) {
 	s.videoMu.Lock()
-	defer s.videoMu.Unlock()
 	s.videoList = append(s.videoList, config.InputItem{Path: videoPath})
+	s.videoMu.Unlock()
+	s.videoAdded(videoPath)
 }
 
-func (s *Streamer) Remove(videoPath string) {
-	var needStop bool // removed video is current playing
-	var removeIndex int = -1
-
-	s.videoMu.Lock()
-	for i, item := range s.videoList {
-		if item.Path == videoPath {
-			removeIndex = i
-
-			s.playStateMu.RLock()
-			needStop = (s.playState.currentVideoIndex == i)
-			s.playStateMu.RUnlock()
+// videoAdded probes a video added to the playlist, the file may have been
+// replaced so its failures are forgotten to give it another chance.
+func (s *Streamer) videoAdded(videoPath string) {
+	go library.GlobalLibrary.Get(videoPath)
 
-			break
-		}
-	}
+	s.playStateMu.Lock()
+	delete(s.playState.failedVideos, videoPath)
+	delete(s.playState.inputFailures, videoPath)
+	s.playStateMu.Unlock()
+}
 
-	if removeIndex >= 0 && removeIndex < len(s.videoList) {
-		oldLen := len(s.videoList)
-		s.videoList = append(s.videoList[:removeIndex], s.videoList[removeIndex+1:]...)
+func (s *Streamer) videoLen() int {
+	s.videoMu.RLock()
+	defer s.videoMu.RUnlock()
+	return len(s.videoList)
+}
 
-		s.playStateMu.Lock()
-		if s.playState.currentVideoIndex >= oldLen-1 {
-			s.playState.currentVideoIndex = 0
-		}
-		s.playStateMu.Unlock()
+func (s *Streamer) Remove(videoPath string) {
+	s.videoMu.Lock()
+	index := slices.IndexFunc(s.videoList, func(item config.InputItem) bool { return item.Path == videoPath })
+	needStop := false // removed video is current playing
+	if index >= 0 {
+		needStop = s.removeLocked(index)
 	}
 	s.videoMu.Unlock()
 
@@ -168,81 +343,149 @@ func (s *Streamer) Remove(videoPath string) {
 	}
 }
 
+// changeLocked marks the video as changed by hand, the index isn't advanced
+// when the current video stops. Must be called with playStateMu held, the
+// caller stops the current video.
+func (s *Streamer) changeLocked() {
+	s.playState.manualControl = true
+	s.playState.retryAt = time.Time{} // a chosen video doesn't wait out the backoff
+}
+
+// selectLocked makes the video at index the next to play, before the queue.
+// Must be called with playStateMu held, the caller stops the current video.
+func (s *Streamer) selectLocked(index int) {
+	s.changeLocked()
+	s.playState.skipQueue = true
+	s.playState.finished = false
+	s.playState.currentVideoIndex = index
+}
+
 func (s *Streamer) Prev() {
-	s.videoMu.RLock()
-	videoLen := len(s.videoList)
+	videoLen := s.videoLen()
 	if videoLen == 0 {
 		return
 	}
-	s.videoMu.RUnlock()
 
 	s.playStateMu.Lock()
-	s.playState.manualControl = true
-	s.playState.currentVideoIndex--
-	if s.playState.currentVideoIndex < 0 {
-		s.playState.currentVideoIndex = videoLen - 1
+	index := s.playState.currentVideoIndex - 1
+	if index < 0 {
+		index = videoLen - 1
 	}
+	s.selectLocked(index)
 	s.playStateMu.Unlock()
 
 	s.Stop()
 }
 
+// Next plays the next queued video, or the next video of the playlist when
+// the queue is empty.
 func (s *Streamer) Next() {
 	s.videoMu.RLock()
-	videoLen := len(s.videoList)
-	if videoLen == 0 {
+	s.playStateMu.Lock()
+	switch {
+	case s.playState.queuedPath != "":
+		// the index already points at where the playlist continues
+		s.playState.queuedPath = ""
+	case len(s.videoList) == 0:
+		s.playStateMu.Unlock()
+		s.videoMu.RUnlock()
 		return
+	case s.playState.finished:
+		// start over
+		s.playState.finished = false
+		s.playState.currentVideoIndex = 0
+	default:
+		s.advanceLocked(advanceNext)
 	}
+	s.changeLocked()
+	s.playStateMu.Unlock()
 	s.videoMu.RUnlock()
 
-	s.playStateMu.Lock()
-	s.playState.manualControl = true
-	s.playState.currentVideoIndex++
-	if s.playState.currentVideoIndex >= videoLen {
-		s.playState.currentVideoIndex = 0
+	s.Stop()
+}
+
+// Jump stops the current video and plays the video at index
+func (s *Streamer) Jump(index int) error {
+	s.videoMu.RLock()
+	videoLen := len(s.videoList)
+	s.videoMu.RUnlock()
+	if index < 0 || index >= videoLen {
+		return fmt.Errorf("index %d out of range, there are %d videos", index, videoLen)
 	}
+
+	s.playStateMu.Lock()
+	s.selectLocked(index)
 	s.playStateMu.Unlock()
 
 	s.Stop()
+	return nil
 }
 
-func (s *Streamer) log(reader *bufio.Reader) {
-	s.playStateMu.RLock()
-	ctx := s.playState.ctx
-	s.playStateMu.RUnlock()
-
-	select {
-	case <-ctx.Done():
-		return
-	default:
-		if !config.GlobalConfig.Log.PlayState {
-			return
+// JumpToPath stops the current video and plays the video at videoPath
+func (s *Streamer) JumpToPath(videoPath string) error {
+	s.videoMu.RLock()
+	index := -1
+	for i, item := range s.videoList {
+		if item.Path == videoPath {
+			index = i
+			break
 		}
-		buf := make([]byte, 1024)
-		for {
-			n, err := reader.Read(buf)
-			if n > 0 {
-				videoPath := s.GetCurrentVideoPath()
-				buf = append([]byte(videoPath), buf...)
-				s.writeOutput(string(buf[:n+len(videoPath)]))
-			}
-			if err != nil {
-				if err != io.EOF {
-					s.writeOutput(fmt.Sprintf("reading ffmpeg output error: %v\n", err))
-				}
-				break
+	}
+	s.videoMu.RUnlock()
+	if index < 0 {
+		return fmt.Errorf("video %s not found", videoPath)
+	}
+	return s.Jump(index)
+}
+
+// log reads ffmpeg's stderr until it is closed, progress reports are parsed
+// into the progress of the current video and the other lines are written to
+// the output. tsOffset is subtracted from the reported time in gapless mode.
+// The last lines are returned to find out why ffmpeg failed, outputs are
+// the destinations ffmpeg pushes to.
+func (s *Streamer) log(reader io.Reader, videoPath string, tsOffset time.Duration, outputs []config.OutputConfig) []string {
+	scanner := bufio.NewScanner(reader)
+	progress := Progress{}
+	tail := make([]string, 0, stderrTailLines)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if key, value, ok := strings.Cut(line, "="); ok && isProgressKey(key) {
+			progress.set(key, value)
+			if key == "progress" {
+				progress.OutTime = max(progress.OutTime-tsOffset.Seconds(), 0)
+				progress.UpdatedAt = time.Now().UnixMilli()
+				s.setProgress(progress)
 			}
+			continue
+		}
+		s.checkTeeFailure(line, outputs)
+		if len(tail) == stderrTailLines {
+			tail = append(tail[:0], tail[1:]...)
+		}
+		tail = append(tail, line)
+		// stderr must be drained even if it is not logged, or ffmpeg blocks
+		if config.Get().Log.PlayState {
+			s.writeOutput(fmt.Sprintf("%s: %s\n", videoPath, line))
 		}
 	}
+	if err := scanner.Err(); err != nil {
+		s.writeOutput(fmt.Sprintf("reading ffmpeg output error: %v\n", err))
+	}
+	return tail
 }
 
 func (s *Streamer) GetCurrentVideoPath() string {
 	s.videoMu.RLock()
 	defer s.videoMu.RUnlock()
-	if len(s.videoList) == 0 {
+	s.playStateMu.RLock()
+	defer s.playStateMu.RUnlock()
+	if s.playState.queuedPath != "" {
+		return s.playState.queuedPath
+	}
+	if s.playState.currentVideoIndex >= len(s.videoList) {
 		return ""
 	}
-	return s.videoList[s.GetCurrentIndex()].Path
+	return s.videoList[s.playState.currentVideoIndex].Path
 }
 
 func (s *Streamer) GetVideoList() []config.InputItem {
@@ -261,30 +504,60 @@ func (s *Streamer) GetVideoListPath() []string {
 	return videoList
 }
 
+// GetCurrentIndex returns the index of the current video, -1 while a queued
+// video is playing.
 func (s *Streamer) GetCurrentIndex() int {
 	s.playStateMu.RLock()
 	defer s.playStateMu.RUnlock()
+	if s.playState.queuedPath != "" {
+		return -1
+	}
 	return s.playState.currentVideoIndex
 }
 
+// writeOutput writes to the log shown to clients, secrets are hidden
 func (s *Streamer) writeOutput(str string) {
-	s.outputMu.Lock()
-	defer s.outputMu.Unlock()
-	s.output.WriteString(str)
+	s.output.Write(config.Redact(str))
+}
+
+// GetOutputSince returns the output lines from cursor on and the cursor for
+// the next call, pass 0 to get every line still kept.
+func (s *Streamer) GetOutputSince(cursor uint64) ([]LogLine, uint64) {
+	return s.output.Since(cursor)
+}
+
+// GetPosition returns the playback position in the current video
+func (s *Streamer) GetPosition() time.Duration {
+	s.playStateMu.RLock()
+	defer s.playStateMu.RUnlock()
+	return s.positionLocked()
 }
 
-func (s *Streamer) GetOutput() string {
-	s.outputMu.RLock()
-	defer s.outputMu.RUnlock()
-	return s.output.String()
+// positionLocked must be called with playStateMu held
+func (s *Streamer) positionLocked() time.Duration {
+	if s.playState.startedAt.IsZero() {
+		return 0
+	}
+	if progress := s.GetProgress(); progress.UpdatedAt != 0 {
+		// ffmpeg may be slower than real time, prefer what it has encoded
+		return s.playState.startOffset + time.Duration(progress.OutTime*float64(time.Second))
+	}
+	return s.playState.startOffset + time.Since(s.playState.startedAt)
 }
 
 func (s *Streamer) Close() {
+	s.saveState()
+	s.playStateMu.Lock()
+	s.playState.closing = true
+	s.playStateMu.Unlock()
 	s.Stop()
+	if s.publisher != nil {
+		s.publisher.close()
+	}
 	os.Exit(0)
 }
 
-func (s *Streamer) buildFFmpegArgs(videoItem config.InputItem) []string {
+func (s *Streamer) buildFFmpegArgs(videoItem config.InputItem, tsOffset time.Duration, outputs []config.OutputConfig) []string {
 	videoPath := videoItem.Path
 
 	args := []string{"-re"}
@@ -296,29 +569,48 @@ func (s *Streamer) buildFFmpegArgs(videoItem config.InputItem) []string {
 		args = append(args, "-to", videoItem.End)
 	}
 
-	args = append(args,
-		"-i", videoPath,
-		"-c:v", config.GlobalConfig.Play.VideoCodec,
-		"-preset", config.GlobalConfig.Play.Preset,
-		"-crf", fmt.Sprintf("%d", config.GlobalConfig.Play.CRF),
-		"-maxrate", config.GlobalConfig.Play.MaxRate,
-		"-bufsize", config.GlobalConfig.Play.BufSize,
-		"-vf", fmt.Sprintf("scale=%s", config.GlobalConfig.Play.Scale),
-		"-r", fmt.Sprintf("%d", config.GlobalConfig.Play.FrameRate),
-		"-c:a", config.GlobalConfig.Play.AudioCodec,
-		"-b:a", config.GlobalConfig.Play.AudioBitrate,
-		"-ar", fmt.Sprintf("%d", config.GlobalConfig.Play.AudioSampleRate),
-		"-f", config.GlobalConfig.Play.OutputFormat,
-	)
-
-	if config.GlobalConfig.Play.CustomArgs != "" {
-		customArgs := strings.Fields(config.GlobalConfig.Play.CustomArgs)
-		args = append(args, customArgs...)
-	}
+	args = append(args, "-i", videoPath, "-map", "0:v:0", "-map", "0:a:0?")
+	args = append(args, s.buildEncodeArgs()...)
+	args = append(args, "-nostats", "-progress", "pipe:2")
+	args = append(args, s.buildDestinationArgs(tsOffset, outputs)...)
 
-	args = append(args, fmt.Sprintf("%s/%s", config.GlobalConfig.Output.RTMPServer, config.GlobalConfig.Output.StreamKey))
+	log.Println("ffmpeg args: ", config.RedactArgs(args))
 
-	log.Println("ffmpeg args: ", args)
+	return args
+}
+
+// buildEncodeArgs returns the encoding part of the ffmpeg args
+func (s *Streamer) buildEncodeArgs() []string {
+	play := config.Get().Play
+	args := []string{
+		"-c:v", play.VideoCodec,
+		"-preset", play.Preset,
+		"-crf", fmt.Sprintf("%d", play.CRF),
+		"-maxrate", play.MaxRate,
+		"-bufsize", play.BufSize,
+		"-vf", fmt.Sprintf("scale=%s", play.Scale),
+		"-r", fmt.Sprintf("%d", play.FrameRate),
+		"-c:a", play.AudioCodec,
+		"-b:a", play.AudioBitrate,
+		"-ar", fmt.Sprintf("%d", play.AudioSampleRate),
+	}
 
+	if play.CustomArgs != "" {
+		customArgs := strings.Fields(play.CustomArgs)
+		args = append(args, customArgs...)
+	}
 	return args
 }
+
+// buildDestinationArgs returns where the encoded stream is written to
+func (s *Streamer) buildDestinationArgs(tsOffset time.Duration, outputs []config.OutputConfig) []string {
+	if s.publisher != nil {
+		// gapless mode, hand mpegts over to the publisher
+		return []string{
+			"-f", "mpegts",
+			"-output_ts_offset", fmt.Sprintf("%.3f", tsOffset.Seconds()),
+			"pipe:1",
+		}
+	}
+	return s.buildOutputArgs(outputs)
+}
@@ -0,0 +1,41 @@
+package streamer
+
+import (
+	"live-streamer/config"
+	"live-streamer/constant"
+	"live-streamer/library"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "streamer-test")
+	if err != nil {
+		panic(err)
+	}
+	library.NewLibrary(filepath.Join(dir, "media_cache.json"))
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// newTestStreamer returns a streamer playing paths in sequential mode,
+// without running ffmpeg.
+func newTestStreamer(paths ...string) *Streamer {
+	s := &Streamer{
+		playState: playState{
+			inputFailures: make(map[string]int),
+			failedVideos:  make(map[string]string),
+			mode:          constant.PlayModeSequential,
+			shufflePlayed: make(map[string]bool),
+		},
+		output:        newLogBuffer(100),
+		outputEnabled: make(map[string]bool),
+		outputHealth:  make(map[string]*outputHealth),
+	}
+	for _, path := range paths {
+		s.videoList = append(s.videoList, config.InputItem{Path: path})
+	}
+	return s
+}
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseDuration parses ffmpeg style time durations, e.g. "01:02:03.5",
+// "02:03", "90", "10s" or "1500ms".
+func ParseDuration(str string) (time.Duration, error) {
+	str = strings.TrimSpace(str)
+	if str == "" {
+		return 0, fmt.Errorf("empty duration")
+	}
+	sign := time.Duration(1)
+	switch str[0] {
+	case '-':
+		sign = -1
+		str = str[1:]
+	case '+':
+		str = str[1:]
+	}
+
+	if strings.Contains(str, ":") {
+		parts := strings.Split(str, ":")
+		if len(parts) > 3 {
+			return 0, fmt.Errorf("invalid duration: %s", str)
+		}
+		var seconds float64
+		for _, part := range parts {
+			value, err := strconv.ParseFloat(part, 64)
+			if err != nil || value < 0 {
+				return 0, fmt.Errorf("invalid duration: %s", str)
+			}
+			seconds = seconds*60 + value
+		}
+		return sign * time.Duration(seconds*float64(time.Second)), nil
+	}
+
+	if seconds, err := strconv.ParseFloat(str, 64); err == nil && seconds >= 0 {
+		return sign * time.Duration(seconds*float64(time.Second)), nil
+	}
+	d, err := time.ParseDuration(str)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration: %s", str)
+	}
+	return sign * d, nil
+}
+
+// FormatDuration formats d as HH:MM:SS.mmm, which ffmpeg also accepts
+func FormatDuration(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		str     string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"01:02:03.5", time.Hour + 2*time.Minute + 3500*time.Millisecond, false},
+		{"02:03", 2*time.Minute + 3*time.Second, false},
+		{"90", 90 * time.Second, false},
+		{"1.25", 1250 * time.Millisecond, false},
+		{"10s", 10 * time.Second, false},
+		{"1500ms", 1500 * time.Millisecond, false},
+		{" 1m30s ", 90 * time.Second, false},
+		{"+30", 30 * time.Second, false},
+		{"-30", -30 * time.Second, false},
+		{"-1:30", -90 * time.Second, false},
+		{"+00:01:00", time.Minute, false},
+		{"-10s", -10 * time.Second, false},
+		{"-0", 0, false},
+		{"", 0, true},
+		{"-", 0, true},
+		{"--30", 0, true},
+		{"+-30", 0, true},
+		{"-1:-30", 0, true},
+		{"1:2:3:4", 0, true},
+		{"1::2", 0, true},
+		{"abc", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDuration(tt.str)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseDuration(%q) error = %v, want error %v", tt.str, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseDuration(%q) = %v, want %v", tt.str, got, tt.want)
+		}
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "00:00:00.000"},
+		{time.Hour + 2*time.Minute + 3500*time.Millisecond, "01:02:03.500"},
+		{26 * time.Hour, "26:00:00.000"},
+		{-time.Second, "00:00:00.000"},
+	}
+	for _, tt := range tests {
+		if got := FormatDuration(tt.d); got != tt.want {
+			t.Errorf("FormatDuration(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+		if tt.d >= 0 {
+			if back, err := ParseDuration(tt.want); err != nil || back != tt.d {
+				t.Errorf("ParseDuration(%q) = %v, %v, want %v", tt.want, back, err, tt.d)
+			}
+		}
+	}
+}
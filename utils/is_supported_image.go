@@ -0,0 +1,13 @@
+package utils
+
+import (
+	"live-streamer/constant"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+func IsSupportedImage(filename string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	return slices.Contains(constant.SupportedImageFormats, strings.TrimPrefix(ext, "."))
+}
@@ -1,35 +1,187 @@
 package websocket
 
 import (
+	"encoding/json"
+	"fmt"
+	"live-streamer/library"
 	"live-streamer/streamer"
 )
 
 type RequestType string
 
 const (
-	TypeStreamNextVideo RequestType = "StreamNextVideo"
-	TypeStreamPrevVideo RequestType = "StreamPrevVideo"
-	TypeQuit            RequestType = "Quit"
+	TypeStreamNextVideo  RequestType = "StreamNextVideo"
+	TypeStreamPrevVideo  RequestType = "StreamPrevVideo"
+	TypeQuit             RequestType = "Quit"
+	TypeSetOutputEnabled RequestType = "SetOutputEnabled"
+	TypeJump             RequestType = "Jump"
+	TypePause            RequestType = "Pause"
+	TypeResume           RequestType = "Resume"
+	TypeSeek             RequestType = "Seek"
+	TypeInsertVideo      RequestType = "InsertVideo"
+	TypeRemoveVideo      RequestType = "RemoveVideo"
+	TypeMoveVideo        RequestType = "MoveVideo"
+	TypeClearPlaylist    RequestType = "ClearPlaylist"
+	TypeEnqueueVideo     RequestType = "EnqueueVideo"
+	TypeDequeueVideo     RequestType = "DequeueVideo"
+	TypeClearQueue       RequestType = "ClearQueue"
+	TypeSetPlayMode      RequestType = "SetPlayMode"
 )
 
 type Request struct {
-	Type RequestType `json:"type"`
+	Type    RequestType     `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+type SetOutputEnabledPayload struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+}
+
+// JumpPayload selects the video by index, or by path when index is absent
+type JumpPayload struct {
+	Index *int   `json:"index,omitempty"`
+	Path  string `json:"path,omitempty"`
+}
+
+// SeekPayload position is absolute like "00:10:00" or relative like "+30s"
+type SeekPayload struct {
+	Position string `json:"position"`
+}
+
+// InsertVideoPayload inserts the video before index, or appends it when
+// index is absent
+type InsertVideoPayload struct {
+	Index *int   `json:"index,omitempty"`
+	Path  string `json:"path"`
+}
+
+type RemoveVideoPayload struct {
+	Index int `json:"index"`
+}
+
+type MoveVideoPayload struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+type EnqueueVideoPayload struct {
+	Path string `json:"path"`
+}
+
+// DequeueVideoPayload index is the position in the queue
+type DequeueVideoPayload struct {
+	Index int `json:"index"`
+}
+
+// SetPlayModePayload mode is one of constant.SupportedPlayModes
+type SetPlayModePayload struct {
+	Mode string `json:"mode"`
 }
 
 type Date struct {
-	Timestamp        int64    `json:"timestamp"`
-	CurrentVideoPath string   `json:"currentVideoPath"`
-	VideoList        []string `json:"videoList"`
-	Output           string   `json:"output"`
+	Timestamp        int64                        `json:"timestamp"`
+	CurrentVideoPath string                       `json:"currentVideoPath"`
+	CurrentIndex     int                          `json:"currentIndex"` // -1 while a queued video is playing
+	Paused           bool                         `json:"paused"`
+	PlayMode         string                       `json:"playMode"`
+	Finished         bool                         `json:"finished"` // played through in once mode
+	Position         float64                      `json:"position"` // seconds
+	Progress         streamer.Progress            `json:"progress"`
+	FrameRate        int                          `json:"frameRate"` // target of progress.fps
+	VideoList        []string                     `json:"videoList"`
+	Media            map[string]library.MediaInfo `json:"media"`          // probed videos of videoList by path
+	Queue            []string                     `json:"queue"`          // played before the playlist continues
+	Logs             []streamer.LogLine           `json:"logs,omitempty"` // output lines the client hasn't received yet
+	Outputs          []streamer.OutputStatus      `json:"outputs"`
+	FailedVideos     map[string]string            `json:"failedVideos"` // path to reason
+	BadMedia         map[string]string            `json:"badMedia"`     // videos that failed the check, path to reason
 }
 
-func RequestHandler(reqType RequestType) {
-	switch reqType {
+func RequestHandler(req Request) error {
+	switch req.Type {
 	case TypeStreamNextVideo:
 		streamer.GlobalStreamer.Next()
 	case TypeStreamPrevVideo:
 		streamer.GlobalStreamer.Prev()
 	case TypeQuit:
 		streamer.GlobalStreamer.Close()
+	case TypeSetOutputEnabled:
+		var payload SetOutputEnabledPayload
+		if err := json.Unmarshal(req.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid payload: %v", err)
+		}
+		return streamer.GlobalStreamer.SetOutputEnabled(payload.Name, payload.Enabled)
+	case TypeJump:
+		var payload JumpPayload
+		if err := json.Unmarshal(req.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid payload: %v", err)
+		}
+		if payload.Index != nil {
+			return streamer.GlobalStreamer.Jump(*payload.Index)
+		}
+		return streamer.GlobalStreamer.JumpToPath(payload.Path)
+	case TypePause:
+		return streamer.GlobalStreamer.Pause()
+	case TypeResume:
+		return streamer.GlobalStreamer.Resume()
+	case TypeSeek:
+		var payload SeekPayload
+		if err := json.Unmarshal(req.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid payload: %v", err)
+		}
+		return streamer.GlobalStreamer.Seek(payload.Position)
+	case TypeInsertVideo:
+		var payload InsertVideoPayload
+		if err := json.Unmarshal(req.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid payload: %v", err)
+		}
+		return InsertVideo(payload)
+	case TypeRemoveVideo:
+		var payload RemoveVideoPayload
+		if err := json.Unmarshal(req.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid payload: %v", err)
+		}
+		return streamer.GlobalStreamer.RemoveAt(payload.Index)
+	case TypeMoveVideo:
+		var payload MoveVideoPayload
+		if err := json.Unmarshal(req.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid payload: %v", err)
+		}
+		return streamer.GlobalStreamer.Move(payload.From, payload.To)
+	case TypeClearPlaylist:
+		return streamer.GlobalStreamer.Clear()
+	case TypeEnqueueVideo:
+		var payload EnqueueVideoPayload
+		if err := json.Unmarshal(req.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid payload: %v", err)
+		}
+		return streamer.GlobalStreamer.Enqueue(payload.Path)
+	case TypeDequeueVideo:
+		var payload DequeueVideoPayload
+		if err := json.Unmarshal(req.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid payload: %v", err)
+		}
+		return streamer.GlobalStreamer.RemoveFromQueue(payload.Index)
+	case TypeClearQueue:
+		return streamer.GlobalStreamer.ClearQueue()
+	case TypeSetPlayMode:
+		var payload SetPlayModePayload
+		if err := json.Unmarshal(req.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid payload: %v", err)
+		}
+		return streamer.GlobalStreamer.SetPlayMode(payload.Mode)
+	default:
+		return fmt.Errorf("unknown request type: %s", req.Type)
+	}
+	return nil
+}
+
+// InsertVideo inserts the video of payload, at the end of the playlist when
+// no index is given.
+func InsertVideo(payload InsertVideoPayload) error {
+	if payload.Index == nil {
+		return streamer.GlobalStreamer.Append(payload.Path)
 	}
+	return streamer.GlobalStreamer.Insert(*payload.Index, payload.Path)
 }